@@ -0,0 +1,47 @@
+// Command create-admin creates the first admin account, or promotes an existing account to admin.
+//
+//	ADMIN_PASSWORD=... go run ./cmd/create-admin -email admin@example.com -name "Admin"
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the admin account (required)")
+	name := flag.String("name", "Admin", "name of the admin account")
+	flag.Parse()
+
+	if *email == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Load environment variables from the .env file
+	if err := godotenv.Load(); err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	// The password is read from the environment to keep it out of the shell history
+	password := os.Getenv("ADMIN_PASSWORD")
+
+	// Connect to MongoDB
+	config.ConnectDB()
+
+	if err := services.SeedRoles(); err != nil {
+		log.Fatal("Error seeding roles:", err)
+	}
+
+	user, err := services.BootstrapAdmin(*name, *email, password)
+	if err != nil {
+		log.Fatal("Error creating admin:", err)
+	}
+
+	log.Println("Admin account ready:", user.Email, user.UserID)
+}
@@ -5,7 +5,9 @@ import (
 	"os"
 
 	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
 	"github.com/alpha-154/crud-go-gin/internal/routes"
+	"github.com/alpha-154/crud-go-gin/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
@@ -19,6 +21,35 @@ func main() {
 	// Connect to MongoDB
 	config.ConnectDB()
 
+	// Make sure collection indexes exist
+	if err := services.EnsureIndexes(); err != nil {
+		log.Fatal("Error creating indexes:", err)
+	}
+
+	// Make sure the built-in roles exist
+	if err := services.SeedRoles(); err != nil {
+		log.Fatal("Error seeding roles:", err)
+	}
+
+	services.LoadPasswordPolicy()
+
+	if err := services.BackfillRestaurantSearchGrams(); err != nil {
+		log.Fatal("Error preparing restaurant search:", err)
+	}
+
+	// Give restaurants from before ownership existed an owner, see RESTAURANT_BACKFILL_OWNER
+	if err := services.BackfillRestaurantOwners(); err != nil {
+		log.Fatal("Error assigning restaurant owners:", err)
+	}
+
+	// Fill in restaurant locations from their addresses in the background
+	if err := services.StartGeocoder(); err != nil {
+		log.Fatal("Error starting geocoder:", err)
+	}
+
+	// Load the JWT signing keys and keep rotating them
+	helpers.GetKeyManager().StartRotation()
+
 	// Create a new Gin router
 	router := gin.Default()
 
@@ -0,0 +1,15 @@
+package config
+
+import "os"
+
+// Email verification modes, selected with REQUIRE_EMAIL_VERIFICATION
+const (
+	EmailVerificationOptional = ""       // unverified users can do everything
+	EmailVerificationSignIn   = "signin" // unverified users cannot sign in
+	EmailVerificationWrites   = "writes" // unverified users cannot modify restaurants
+)
+
+// EmailVerificationMode returns how strictly email verification is enforced.
+func EmailVerificationMode() string {
+	return os.Getenv("REQUIRE_EMAIL_VERIFICATION")
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPIKey issues a personal API key; the key is only shown in this response
+func CreateAPIKey(c *gin.Context) {
+	var input dto.CreateAPIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := services.CreateAPIKey(c.GetString("user_id"), input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetAPIKeys lists the personal API keys of the signed-in user
+func GetAPIKeys(c *gin.Context) {
+	keys, err := services.GetAPIKeys(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey revokes one of the signed-in user's API keys
+func RevokeAPIKey(c *gin.Context) {
+	if err := services.RevokeAPIKey(c.GetString("user_id"), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "api key revoked"})
+}
@@ -1,31 +1,55 @@
 package controllers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/alpha-154/crud-go-gin/internal/dto"
 	"github.com/alpha-154/crud-go-gin/internal/helpers"
-	"github.com/alpha-154/crud-go-gin/internal/models"
+	"github.com/alpha-154/crud-go-gin/internal/passwords"
 	"github.com/alpha-154/crud-go-gin/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
+// refreshCookieMaxAge keeps the refresh token cookie, in seconds, for as long as the token is valid
+const refreshCookieMaxAge = int(helpers.RefreshTokenTTL / time.Second)
+
+// clientInfo extracts the device details of the request
+func clientInfo(c *gin.Context) dto.ClientInfo {
+	return dto.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+// passwordErrorResponse lists the broken password rules, and reports other errors as usual
+func passwordErrorResponse(c *gin.Context, err error, status int) {
+	var policyErr *passwords.PolicyError
+	if errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password does not meet the policy", "violations": policyErr.Violations})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
 // SignUp handles the request to sign up a new user
 func SignUp(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var input dto.SignUpInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	result, err := services.SignUp(user)
+	result, err := services.SignUp(input)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		passwordErrorResponse(c, err, http.StatusBadRequest)
 		return
 	}
 
-	c.JSON(http.StatusCreated, result)
+	c.JSON(http.StatusCreated, dto.NewUserView(result))
 }
 
 // SignIn handles the request to sign in a user
@@ -36,12 +60,24 @@ func SignIn(c *gin.Context) {
 		return
 	}
 
-	tokens, err := services.SignIn(input)
+	tokens, err := services.SignIn(input, clientInfo(c))
+	var lockout *services.LockoutError
+	if errors.As(err, &lockout) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Second step required, see VerifyMFA
+	if tokens.MFARequired {
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": tokens.MFAToken})
+		return
+	}
+
 	//c.JSON(http.StatusOK, tokens)
 
 	// Set new refresh token in HTTP-only cookie
@@ -64,6 +100,66 @@ func SignIn(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"access_token": tokens.AccessToken})
 }
 
+// ForgotPassword emails a password reset link
+func ForgotPassword(c *gin.Context) {
+	var input dto.ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.RequestPasswordReset(input.Email)
+
+	// Same answer whether or not the email exists
+	c.JSON(http.StatusOK, gin.H{"message": "if the email is registered, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password using a reset token
+func ResetPassword(c *gin.Context) {
+	var input dto.ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.ResetPassword(input.Token, input.Password); err != nil {
+		passwordErrorResponse(c, err, http.StatusBadRequest)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password has been reset"})
+}
+
+// VerifyEmail confirms the email address of a user with the token from the verification email
+func VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing token parameter"})
+		return
+	}
+
+	if err := services.VerifyEmail(token); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+// ResendVerification sends a new verification email
+func ResendVerification(c *gin.Context) {
+	var input dto.ResendVerificationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.ResendVerificationEmail(input.Email)
+
+	// Same answer whether or not the email exists, needs verification or was throttled
+	c.JSON(http.StatusOK, gin.H{"message": "if the email needs verification, a new link has been sent"})
+}
+
 // GetUser retrieves a user by ID
 func GetUser(c *gin.Context) {
 	userID := c.Param("id")
@@ -73,28 +169,35 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, dto.NewUserView(user))
 }
 
-// GetAllUsers retrieves all users
+// GetAllUsers retrieves all users, optionally filtered by ?q=, ?role= and ?disabled=
 func GetAllUsers(c *gin.Context) {
-	users, err := services.GetAllUsers()
+	var query dto.UserSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users, err := services.GetAllUsers(query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, dto.NewUserViews(users))
 }
 
-// Logout handles the request to log out a user
+// Logout signs out the session identified by the refresh cookie or the access token
 func Logout(c *gin.Context) {
-	userID := c.Param("user_id")
+	refreshToken, _ := c.Cookie("refresh_token")
+	accessToken := helpers.ExtractBearerToken(c.GetHeader("Authorization"))
 
-	// Remove refresh token from database
-	err := services.InvalidateUserTokens(userID)
+	// Revoke only the caller's own session
+	err := services.Logout(refreshToken, accessToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "logout failed"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -105,33 +208,77 @@ func Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
 }
 
-// Refresh token endpoint
-func RefreshToken(c *gin.Context) {
-	// Get refresh token from HTTP-only cookie
-	refreshToken, err := c.Cookie("refresh_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token required"})
+// LogoutUser forcibly signs a user out of every session
+func LogoutUser(c *gin.Context) {
+	userID := c.Param("id")
+
+	if _, err := services.GetUserByID(userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
 
-	// Validate refresh token
-	claims, err := helpers.ValidateRefreshToken(refreshToken)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+	if err := services.InvalidateUserTokens(userID, c.GetString("user_id")); err != nil {
+		if errors.Is(err, services.ErrInsufficientPermissions) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "logout failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user logged out from all sessions"})
+}
+
+// SetUserRole assigns a role to a user
+func SetUserRole(c *gin.Context) {
+	var input dto.SetRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Prevents admins from locking themselves out
+	userID := c.Param("id")
+	if userID == c.GetString("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot change your own role"})
+		return
+	}
+
+	if err := services.SetUserRole(userID, input.Role, c.GetString("user_id")); err != nil {
+		c.JSON(userErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role updated"})
+}
+
+// UnlockUser clears the sign-in lockout of a user
+func UnlockUser(c *gin.Context) {
+	if err := services.UnlockUser(c.Param("id"), c.GetString("user_id")); err != nil {
+		if errors.Is(err, services.ErrInsufficientPermissions) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
 
-	// Get user from database to verify token hasn't been revoked
-	user, err := services.GetUserByID(claims["user_id"].(string))
-	if err != nil || user.RefreshToken != refreshToken {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+	c.JSON(http.StatusOK, gin.H{"message": "user unlocked"})
+}
+
+// Refresh token endpoint
+func RefreshToken(c *gin.Context) {
+	// Get refresh token from HTTP-only cookie
+	refreshToken, err := c.Cookie("refresh_token")
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token required"})
 		return
 	}
 
-	// Generate new token pair
-	tokens, err := helpers.GenerateTokenPair(user.UserID, user.Role)
+	// Rotate the refresh token; reused or revoked tokens are rejected
+	tokens, err := services.RotateRefreshToken(refreshToken, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -0,0 +1,193 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLogoutWithoutCredentials(t *testing.T) {
+	resetDB(t)
+
+	w := request(t, http.MethodPost, "/api/auth/logout", "", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("logout without a token: got %d, want 401", w.Code)
+	}
+
+	w = request(t, http.MethodPost, "/api/auth/logout", "not-a-token", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("logout with a malformed token: got %d, want 401", w.Code)
+	}
+}
+
+func TestLogoutOnlyEndsTheCallersSession(t *testing.T) {
+	resetDB(t)
+	alice := signUp(t, "Alice", "")
+	bob := signUp(t, "Bob", "")
+
+	// The old route that took the victim's ID is gone
+	w := request(t, http.MethodPost, "/api/auth/logout/"+bob.ID, alice.AccessToken, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("logout by user ID: got %d, want 404", w.Code)
+	}
+
+	w = request(t, http.MethodPost, "/api/auth/logout", alice.AccessToken, map[string]string{"user_id": bob.ID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("logout: got %d %s, want 200", w.Code, w.Body)
+	}
+
+	if w := request(t, http.MethodGet, "/api/me", alice.AccessToken, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Alice's token after her logout: got %d, want 401", w.Code)
+	}
+	if w := request(t, http.MethodGet, "/api/me", bob.AccessToken, nil); w.Code != http.StatusOK {
+		t.Errorf("Bob's token after Alice's logout: got %d, want 200", w.Code)
+	}
+	refresh := &http.Cookie{Name: "refresh_token", Value: bob.RefreshToken}
+	if w := request(t, http.MethodPost, "/api/auth/refresh", "", nil, refresh); w.Code != http.StatusOK {
+		t.Errorf("Bob's refresh token after Alice's logout: got %d %s, want 200", w.Code, w.Body)
+	}
+}
+
+func TestLogoutWithAnotherUsersRevokedToken(t *testing.T) {
+	resetDB(t)
+	alice := signUp(t, "Alice", "")
+	bob := signUp(t, "Bob", "")
+
+	if w := request(t, http.MethodPost, "/api/auth/logout", bob.AccessToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("Bob's logout: got %d, want 200", w.Code)
+	}
+
+	// Replaying Bob's token must neither work nor touch Alice's session
+	if w := request(t, http.MethodGet, "/api/me", bob.AccessToken, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Bob's token after logout: got %d, want 401", w.Code)
+	}
+	if w := request(t, http.MethodGet, "/api/me", alice.AccessToken, nil); w.Code != http.StatusOK {
+		t.Errorf("Alice's token: got %d, want 200", w.Code)
+	}
+}
+
+func TestForcedLogoutRequiresAdmin(t *testing.T) {
+	resetDB(t)
+	mallory := signUp(t, "Mallory", "")
+	victim := signUp(t, "Victim", "")
+
+	w := request(t, http.MethodPost, "/api/users/"+victim.ID+"/logout", mallory.AccessToken, nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("forced logout by a regular user: got %d, want 403", w.Code)
+	}
+	if w := request(t, http.MethodGet, "/api/me", victim.AccessToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("victim's token after a refused forced logout: got %d, want 200", w.Code)
+	}
+
+	w = request(t, http.MethodPost, "/api/users/"+victim.ID+"/logout", "", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("forced logout without a token: got %d, want 401", w.Code)
+	}
+
+	admin := signUp(t, "Admin", models.RoleAdmin)
+	w = request(t, http.MethodPost, "/api/users/"+victim.ID+"/logout", admin.AccessToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("forced logout by an admin: got %d %s, want 200", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodGet, "/api/me", victim.AccessToken, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("victim's token after a forced logout: got %d, want 401", w.Code)
+	}
+}
+
+func TestResponsesNeverExposeSecrets(t *testing.T) {
+	resetDB(t)
+	admin := signUp(t, "Admin", models.RoleAdmin)
+	alice := signUp(t, "Alice", "")
+
+	// Give Alice every kind of secret a user document can hold
+	recoveryCode := "recovery-code-1"
+	users := config.GetCollection(config.DB, "users")
+	_, err := users.UpdateOne(context.Background(), bson.M{"user_id": alice.ID}, bson.M{"$set": bson.M{
+		"mfa_secret":         "STOREDTOTPSECRET",
+		"mfa_pending_secret": "PENDINGTOTPSECRET",
+		"mfa_recovery_codes": bson.A{helpers.HashToken(helpers.NormalizeRecoveryCode(recoveryCode))},
+		"password_history":   bson.A{"$2a$10$previouspasswordhash"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored models.User
+	if err := users.FindOne(context.Background(), bson.M{"user_id": alice.ID}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+
+	forbidden := []string{
+		`"password"`, `"password_history"`, `"mfa_secret"`, `"mfa_pending_secret"`, `"mfa_recovery_codes"`,
+		stored.Password, stored.MFASecret, stored.MFAPendingSecret, stored.MFARecoveryCodes[0], stored.PasswordHistory[0],
+	}
+	check := func(name string, w *httptest.ResponseRecorder) {
+		t.Helper()
+		if w.Code >= 300 {
+			t.Fatalf("%s: got %d %s", name, w.Code, w.Body)
+		}
+		for _, secret := range forbidden {
+			if strings.Contains(w.Body.String(), secret) {
+				t.Errorf("%s exposes %s: %s", name, secret, w.Body)
+			}
+		}
+	}
+
+	check("sign up", request(t, http.MethodPost, "/api/auth/signup", "", map[string]string{"name": "Carol", "email": "carol@example.com", "password": testPassword}))
+	signin := request(t, http.MethodPost, "/api/auth/signin", "", map[string]string{"email": alice.Email, "password": testPassword})
+	check("sign in", signin)
+	check("get me", request(t, http.MethodGet, "/api/me", alice.AccessToken, nil))
+	check("update me", request(t, http.MethodPatch, "/api/me", alice.AccessToken, map[string]string{"name": "Alice A."}))
+	check("get own user", request(t, http.MethodGet, "/api/users/"+alice.ID, alice.AccessToken, nil))
+	check("sessions", request(t, http.MethodGet, "/api/auth/sessions", alice.AccessToken, nil))
+	check("get user", request(t, http.MethodGet, "/api/users/"+alice.ID, admin.AccessToken, nil))
+	check("list users", request(t, http.MethodGet, "/api/users", admin.AccessToken, nil))
+	check("update user", request(t, http.MethodPut, "/api/users/"+alice.ID, admin.AccessToken, map[string]string{"name": "Alice B."}))
+	check("create user", request(t, http.MethodPost, "/api/users", admin.AccessToken, map[string]string{"name": "Dave", "email": "dave@example.com", "password": testPassword}))
+	check("user sessions", request(t, http.MethodGet, "/api/users/"+alice.ID+"/sessions", admin.AccessToken, nil))
+	check("refresh", request(t, http.MethodPost, "/api/auth/refresh", "", nil, &http.Cookie{Name: "refresh_token", Value: alice.RefreshToken}))
+
+	// The two-step sign-in
+	if _, err := users.UpdateOne(context.Background(), bson.M{"user_id": alice.ID}, bson.M{"$set": bson.M{"mfa_enabled": true}}); err != nil {
+		t.Fatal(err)
+	}
+	challenge := request(t, http.MethodPost, "/api/auth/signin", "", map[string]string{"email": alice.Email, "password": testPassword})
+	check("sign in with MFA", challenge)
+	var body struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	decode(t, challenge, &body)
+	check("verify MFA", request(t, http.MethodPost, "/api/auth/mfa/verify", "", map[string]string{"mfa_token": body.MFAToken, "code": recoveryCode}))
+}
+
+func TestMFASignInSetsRefreshCookie(t *testing.T) {
+	resetDB(t)
+	alice := signUp(t, "Alice", "")
+	recoveryCode := "recovery-code-1"
+	_, err := config.GetCollection(config.DB, "users").UpdateOne(context.Background(), bson.M{"user_id": alice.ID}, bson.M{"$set": bson.M{
+		"mfa_enabled":        true,
+		"mfa_secret":         "STOREDTOTPSECRET",
+		"mfa_recovery_codes": bson.A{helpers.HashToken(helpers.NormalizeRecoveryCode(recoveryCode))},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge := request(t, http.MethodPost, "/api/auth/signin", "", map[string]string{"email": alice.Email, "password": testPassword})
+	var body struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	decode(t, challenge, &body)
+	w := request(t, http.MethodPost, "/api/auth/mfa/verify", "", map[string]string{"mfa_token": body.MFAToken, "code": recoveryCode})
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify MFA: got %d %s", w.Code, w.Body)
+	}
+	checkRefreshCookie(t, w)
+}
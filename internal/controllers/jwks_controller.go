@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publishes the public signing keys so other services can validate our tokens
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, helpers.GetKeyManager().JWKS())
+}
@@ -0,0 +1,180 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/mailer"
+	"github.com/alpha-154/crud-go-gin/internal/mongotest"
+	"github.com/alpha-154/crud-go-gin/internal/routes"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testPassword = "Correct-Horse-9"
+
+var (
+	db     *mongotest.Database
+	router *gin.Engine
+)
+
+// discardMailer drops every message.
+type discardMailer struct{}
+
+func (discardMailer) Send(context.Context, mailer.Message) error { return nil }
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Setenv("JWT_EPHEMERAL_KEYS", "true")
+	db = mongotest.Setup()
+	if db != nil {
+		if err := services.EnsureIndexes(); err != nil {
+			panic(err)
+		}
+	}
+	services.SetMailer(discardMailer{})
+
+	router = gin.New()
+	routes.SetupRoutes(router)
+	code := m.Run()
+	db.Drop()
+	os.Exit(code)
+}
+
+// resetDB empties the database and seeds the built-in roles, or skips the test
+// when there is no database server.
+func resetDB(t *testing.T) {
+	t.Helper()
+	db.Reset(t)
+	if err := services.SeedRoles(); err != nil {
+		t.Fatal("seeding roles:", err)
+	}
+}
+
+// testUser is a signed-up user signed in on one device.
+type testUser struct {
+	ID           string
+	Email        string
+	AccessToken  string
+	RefreshToken string
+}
+
+// signUp registers a user with the given role and signs them in.
+func signUp(t *testing.T, name string, role string) testUser {
+	t.Helper()
+	email := strings.ToLower(name) + "@example.com"
+	user, err := services.SignUp(dto.SignUpInput{Name: name, Email: email, Password: testPassword})
+	if err != nil {
+		t.Fatal("sign up:", err)
+	}
+	if role != "" {
+		_, err := config.GetCollection(config.DB, "users").UpdateOne(
+			context.Background(),
+			bson.M{"user_id": user.UserID},
+			bson.M{"$set": bson.M{"role": role}},
+		)
+		if err != nil {
+			t.Fatal("set role:", err)
+		}
+	}
+	return signIn(t, user.UserID, email)
+}
+
+func signIn(t *testing.T, userID string, email string) testUser {
+	t.Helper()
+	w := request(t, http.MethodPost, "/api/auth/signin", "", map[string]string{"email": email, "password": testPassword})
+	if w.Code != http.StatusOK {
+		t.Fatalf("sign in: %d %s", w.Code, w.Body)
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	decode(t, w, &body)
+
+	user := testUser{ID: userID, Email: email, AccessToken: body.AccessToken}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "refresh_token" {
+			user.RefreshToken = cookie.Value
+		}
+	}
+	return user
+}
+
+// request sends a JSON request, with a Bearer token when one is given.
+func request(t *testing.T, method string, path string, token string, body interface{}, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body, err)
+	}
+}
+
+// checkRefreshCookie fails unless the response sets a refresh token cookie that lasts as long as the token.
+func checkRefreshCookie(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name != "refresh_token" {
+			continue
+		}
+		if want := int(helpers.RefreshTokenTTL / time.Second); cookie.MaxAge != want {
+			t.Errorf("refresh token cookie Max-Age = %d, want %d", cookie.MaxAge, want)
+		}
+		return
+	}
+	t.Error("no refresh token cookie")
+}
+
+// verifyEmail marks the email address of a user as verified.
+func verifyEmail(t *testing.T, userID string) {
+	t.Helper()
+	_, err := config.GetCollection(config.DB, "users").UpdateOne(
+		context.Background(),
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"email_verified": true}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// countWhere counts the documents of a collection whose field holds the value.
+func countWhere(t *testing.T, collection string, field string, value interface{}) int {
+	t.Helper()
+	n, err := config.GetCollection(config.DB, collection).CountDocuments(context.Background(), bson.M{field: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(n)
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// EnrollMFA starts TOTP enrollment and returns the secret, otpauth URI and QR code
+func EnrollMFA(c *gin.Context) {
+	enrollment, err := services.StartMFAEnrollment(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmMFA enables two-factor authentication and returns the recovery codes
+func ConfirmMFA(c *gin.Context) {
+	var input dto.MFACodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := services.ConfirmMFAEnrollment(c.GetString("user_id"), input.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// DisableMFA turns two-factor authentication off
+func DisableMFA(c *gin.Context) {
+	var input dto.MFACodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.DisableMFA(c.GetString("user_id"), input.Code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// VerifyMFA exchanges the MFA challenge token and a code for real tokens
+func VerifyMFA(c *gin.Context) {
+	var input dto.MFAVerifyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := services.CompleteMFASignIn(input, clientInfo(c))
+	var lockout *services.LockoutError
+	if errors.As(err, &lockout) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.SetCookie("refresh_token", tokens.RefreshToken,
+		refreshCookieMaxAge,
+		"/",
+		"",
+		false, // Secure
+		true,  // HTTP only
+	)
+
+	c.JSON(http.StatusOK, gin.H{"access_token": tokens.AccessToken})
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/alpha-154/crud-go-gin/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetOAuthProviders lists the configured social login providers
+func GetOAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": services.GetOAuthProviders()})
+}
+
+// oauthStateCookie binds a social login to the browser that started it, so an attacker
+// cannot get a victim's browser signed in to the attacker's account with their own callback URL.
+const (
+	oauthStateCookie     = "oauth_state"
+	oauthStateCookiePath = "/api/auth/oauth"
+)
+
+// StartOAuth redirects the user to the provider's login page
+func StartOAuth(c *gin.Context) {
+	authURL, state, err := services.StartOAuthSignIn(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Lax, so the cookie comes back with the provider's top-level redirect
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state,
+		int(services.OAuthStateTTL.Seconds()),
+		oauthStateCookiePath,
+		"",
+		false, // Secure
+		true,  // HTTP only
+	)
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OAuthCallback completes a social login when the provider redirects back
+func OAuthCallback(c *gin.Context) {
+	// The state is single use, so the cookie is cleared whatever the outcome
+	browserState, _ := c.Cookie(oauthStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, "", -1, oauthStateCookiePath, "", false, true)
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": providerError})
+		return
+	}
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(browserState), []byte(c.Query("state"))) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login was not started from this browser"})
+		return
+	}
+
+	tokens, err := services.CompleteOAuthSignIn(c.Param("provider"), c.Query("code"), c.Query("state"), clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Second step required, see VerifyMFA
+	if tokens.MFARequired {
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": tokens.MFAToken})
+		return
+	}
+
+	c.SetCookie("refresh_token", tokens.RefreshToken,
+		refreshCookieMaxAge,
+		"/",
+		"",
+		false, // Secure
+		true,  // HTTP only
+	)
+
+	c.JSON(http.StatusOK, gin.H{"access_token": tokens.AccessToken})
+}
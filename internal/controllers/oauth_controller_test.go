@@ -0,0 +1,300 @@
+package controllers_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/oauth"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// fakeIssuer is an OpenID Connect provider with discovery, authorize, token and userinfo
+// endpoints. Whoever goes through /authorize is signed in as its current identity.
+type fakeIssuer struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	identity map[string]interface{}
+	codes    map[string]fakeGrant
+	tokens   map[string]map[string]interface{}
+	issued   int
+}
+
+// fakeGrant is an authorization code waiting to be exchanged.
+type fakeGrant struct {
+	challenge string
+	identity  map[string]interface{}
+}
+
+// startFakeIssuer runs a fake issuer and configures it as the "fake" social login provider.
+func startFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	issuer := &fakeIssuer{codes: map[string]fakeGrant{}, tokens: map[string]map[string]interface{}{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("/authorize", issuer.authorize)
+	mux.HandleFunc("/token", issuer.token)
+	mux.HandleFunc("/userinfo", issuer.userinfo)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	providersFile := filepath.Join(t.TempDir(), "oauth_providers.json")
+	providersJSON := fmt.Sprintf(`{"fake": {
+		"type": "oidc",
+		"issuer": %q,
+		"client_id": "test-client",
+		"client_secret": "test-secret",
+		"redirect_url": "http://localhost/api/auth/oauth/fake/callback"
+	}}`, issuer.server.URL)
+	if err := os.WriteFile(providersFile, []byte(providersJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	providers, err := oauth.LoadProviders(providersFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	services.SetOAuthProviders(providers)
+	return issuer
+}
+
+// signInAs sets the identity the next authorization is granted for.
+func (f *fakeIssuer) signInAs(subject string, email string, verified interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.identity = map[string]interface{}{"sub": subject, "email": email, "email_verified": verified, "name": "Social " + subject}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 f.server.URL,
+		"authorization_endpoint": f.server.URL + "/authorize",
+		"token_endpoint":         f.server.URL + "/token",
+		"userinfo_endpoint":      f.server.URL + "/userinfo",
+	})
+}
+
+func (f *fakeIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != "test-client" || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.issued++
+	code := fmt.Sprintf("code-%d", f.issued)
+	f.codes[code] = fakeGrant{challenge: q.Get("code_challenge"), identity: f.identity}
+	f.mu.Unlock()
+
+	callback := q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, callback, http.StatusFound)
+}
+
+// token exchanges a code once, and only for the verifier matching its PKCE challenge.
+func (f *fakeIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("client_id") != "test-client" || r.PostForm.Get("client_secret") != "test-secret" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code := r.PostForm.Get("code")
+	grant, ok := f.codes[code]
+	delete(f.codes, code)
+
+	digest := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(digest[:]) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	accessToken := "token-" + code
+	f.tokens[accessToken] = grant.identity
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": accessToken, "token_type": "Bearer"})
+}
+
+func (f *fakeIssuer) userinfo(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	identity, ok := f.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	f.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, identity)
+}
+
+// startSocialLogin starts a login with the fake provider and returns the provider URL
+// the browser is sent to, and the state cookie it is given.
+func startSocialLogin(t *testing.T) (string, *http.Cookie) {
+	t.Helper()
+	w := request(t, http.MethodGet, "/api/auth/oauth/fake/start", "", nil)
+	if w.Code != http.StatusFound {
+		t.Fatalf("start: got %d %s", w.Code, w.Body)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "oauth_state" {
+			if !cookie.HttpOnly || cookie.MaxAge <= 0 {
+				t.Fatalf("state cookie is not a short-lived HttpOnly cookie: %+v", cookie)
+			}
+			return w.Header().Get("Location"), cookie
+		}
+	}
+	t.Fatal("start: no state cookie")
+	return "", nil
+}
+
+// authorizeAt follows the browser to the provider and returns the callback path it is sent back to.
+func authorizeAt(t *testing.T, authURL string) string {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: got %d", resp.StatusCode)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback.RequestURI()
+}
+
+// socialLogin goes through a whole login with the fake provider.
+func socialLogin(t *testing.T) *httptest.ResponseRecorder {
+	t.Helper()
+	authURL, cookie := startSocialLogin(t)
+	return request(t, http.MethodGet, authorizeAt(t, authURL), "", nil, cookie)
+}
+
+// linkedUser returns the user an identity of the fake provider is linked to, or "".
+func linkedUser(t *testing.T, subject string) string {
+	t.Helper()
+	var link struct {
+		UserID string `bson:"user_id"`
+	}
+	err := config.GetCollection(config.DB, "external_identities").FindOne(
+		context.Background(),
+		bson.M{"provider": "fake", "subject": subject},
+	).Decode(&link)
+	if err != nil {
+		return ""
+	}
+	return link.UserID
+}
+
+func TestSocialLoginVerifiesPKCE(t *testing.T) {
+	resetDB(t)
+	issuer := startFakeIssuer(t)
+	issuer.signInAs("sub-1", "new@example.com", true)
+
+	w := socialLogin(t)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: got %d %s, want 200", w.Code, w.Body)
+	}
+	checkRefreshCookie(t, w)
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	decode(t, w, &body)
+	if w := request(t, http.MethodGet, "/api/me", body.AccessToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("social login token: got %d %s, want 200", w.Code, w.Body)
+	}
+
+	// A code redeemed with another verifier than the one behind the challenge is refused
+	authURL, cookie := startSocialLogin(t)
+	_, err := config.GetCollection(config.DB, "oauth_states").UpdateMany(
+		context.Background(),
+		bson.M{},
+		bson.M{"$set": bson.M{"code_verifier": "not-the-verifier-of-the-challenge-sent-to-the-provider"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := request(t, http.MethodGet, authorizeAt(t, authURL), "", nil, cookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("login with the wrong verifier: got %d %s, want 401", w.Code, w.Body)
+	}
+}
+
+func TestSocialLoginStateIsSingleUseAndBoundToTheBrowser(t *testing.T) {
+	resetDB(t)
+	issuer := startFakeIssuer(t)
+	issuer.signInAs("sub-1", "new@example.com", true)
+
+	authURL, cookie := startSocialLogin(t)
+	_, otherCookie := startSocialLogin(t)
+	callback := authorizeAt(t, authURL)
+
+	if w := request(t, http.MethodGet, callback, "", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("callback without the state cookie: got %d %s, want 401", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodGet, callback, "", nil, otherCookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("callback with another login's cookie: got %d %s, want 401", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodGet, callback, "", nil, cookie); w.Code != http.StatusOK {
+		t.Fatalf("callback: got %d %s, want 200", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodGet, callback, "", nil, cookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed callback: got %d %s, want 401", w.Code, w.Body)
+	}
+}
+
+func TestSocialLoginLinksOnlyVerifiedEmails(t *testing.T) {
+	resetDB(t)
+	issuer := startFakeIssuer(t)
+	alice := signUp(t, "Alice", "")
+	bob := signUp(t, "Bob", "")
+	verifyEmail(t, alice.ID)
+
+	// The provider does not vouch for the address
+	issuer.signInAs("sub-unverified", alice.Email, false)
+	if w := socialLogin(t); w.Code != http.StatusUnauthorized {
+		t.Fatalf("login with an unverified provider email: got %d %s, want 401", w.Code, w.Body)
+	}
+	if linked := linkedUser(t, "sub-unverified"); linked != "" {
+		t.Fatalf("unverified provider email linked to %s", linked)
+	}
+
+	// The local account never proved it owns the address
+	issuer.signInAs("sub-bob", bob.Email, true)
+	if w := socialLogin(t); w.Code != http.StatusUnauthorized {
+		t.Fatalf("login as an unverified local account: got %d %s, want 401", w.Code, w.Body)
+	}
+	if linked := linkedUser(t, "sub-bob"); linked != "" {
+		t.Fatalf("identity linked to unverified account %s", linked)
+	}
+
+	issuer.signInAs("sub-alice", alice.Email, "true")
+	if w := socialLogin(t); w.Code != http.StatusOK {
+		t.Fatalf("login with a verified email: got %d %s, want 200", w.Code, w.Body)
+	}
+	if linked := linkedUser(t, "sub-alice"); linked != alice.ID {
+		t.Fatalf("identity linked to %q, want %q", linked, alice.ID)
+	}
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// oauthErrorResponse writes an error in the RFC 6749 format
+func oauthErrorResponse(c *gin.Context, err error) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": err.Error()})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == "invalid_client" {
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.JSON(status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+}
+
+// clientCredentials reads the client credentials from HTTP Basic auth or the form body
+func clientCredentials(c *gin.Context) (string, string) {
+	if clientID, secret, ok := c.Request.BasicAuth(); ok {
+		return clientID, secret
+	}
+	return c.PostForm("client_id"), c.PostForm("client_secret")
+}
+
+// RegisterOAuthClient registers a third-party app; the secret is only shown in this response
+func RegisterOAuthClient(c *gin.Context) {
+	var input dto.RegisterOAuthClientInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := services.RegisterOAuthClient(c.GetString("user_id"), input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, client)
+}
+
+// GetOAuthClients lists the apps registered by the signed-in user
+func GetOAuthClients(c *gin.Context) {
+	clients, err := services.GetOAuthClients(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+// DeleteOAuthClient removes one of the signed-in user's apps
+func DeleteOAuthClient(c *gin.Context) {
+	if err := services.DeleteOAuthClient(c.GetString("user_id"), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "client deleted"})
+}
+
+// Authorize checks an authorization request for the signed-in user.
+// The front end shows a consent screen or sends the user to redirect_to.
+func Authorize(c *gin.Context) {
+	var req dto.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	response, err := services.AuthorizeOAuthClient(c.GetString("user_id"), req)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Consent records the user's answer on the consent screen
+func Consent(c *gin.Context) {
+	var input dto.ConsentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	response, err := services.ConsentToOAuthClient(c.GetString("user_id"), input)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// OAuthToken is the token endpoint used by third-party apps
+func OAuthToken(c *gin.Context) {
+	var req dto.OAuthTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	req.ClientID, req.ClientSecret = clientCredentials(c)
+
+	tokens, err := services.ExchangeOAuthToken(req)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
+
+// IntrospectToken reports whether a token issued to the calling app is active
+func IntrospectToken(c *gin.Context) {
+	clientID, secret := clientCredentials(c)
+
+	response, err := services.IntrospectOAuthToken(clientID, secret, c.PostForm("token"))
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeToken revokes a token issued to the calling app
+func RevokeToken(c *gin.Context) {
+	clientID, secret := clientCredentials(c)
+
+	if err := services.RevokeOAuthToken(clientID, secret, c.PostForm("token")); err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// GetOAuthConsents lists the apps the signed-in user has granted access to
+func GetOAuthConsents(c *gin.Context) {
+	consents, err := services.GetOAuthConsents(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, consents)
+}
+
+// RevokeOAuthConsent withdraws the signed-in user's consent for an app
+func RevokeOAuthConsent(c *gin.Context) {
+	if err := services.RevokeOAuthConsent(c.GetString("user_id"), c.Param("client_id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "consent revoked"})
+}
@@ -0,0 +1,251 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+)
+
+const (
+	appRedirectURI = "https://app.example.com/callback"
+	appVerifier    = "a-code-verifier-that-is-long-enough-for-pkce-0123456789"
+)
+
+// testApp is a third-party app registered with the authorization server.
+type testApp struct {
+	ClientID string
+	Secret   string
+}
+
+// registerApp registers an app owned by the user that may ask for reading restaurants.
+func registerApp(t *testing.T, owner testUser, confidential bool) testApp {
+	t.Helper()
+	w := request(t, http.MethodPost, "/api/oauth/clients", owner.AccessToken, map[string]interface{}{
+		"name":          "App",
+		"redirect_uris": []string{appRedirectURI},
+		"scopes":        []string{models.PermissionRestaurantsRead},
+		"confidential":  confidential,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register app: got %d %s", w.Code, w.Body)
+	}
+	var body struct {
+		ClientSecret string `json:"client_secret"`
+		Client       struct {
+			ClientID string `json:"client_id"`
+		} `json:"client"`
+	}
+	decode(t, w, &body)
+	return testApp{ClientID: body.Client.ClientID, Secret: body.ClientSecret}
+}
+
+// authorizeApp has the user approve the app and returns the authorization code it is sent.
+func authorizeApp(t *testing.T, user testUser, app testApp) string {
+	t.Helper()
+	w := request(t, http.MethodPost, "/api/oauth/authorize", user.AccessToken, map[string]interface{}{
+		"response_type":         "code",
+		"client_id":             app.ClientID,
+		"redirect_uri":          appRedirectURI,
+		"scope":                 models.PermissionRestaurantsRead,
+		"state":                 "xyz",
+		"code_challenge":        helpers.PKCEChallenge(appVerifier),
+		"code_challenge_method": "S256",
+		"approve":               true,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("authorize: got %d %s", w.Code, w.Body)
+	}
+	var body struct {
+		RedirectTo string `json:"redirect_to"`
+	}
+	decode(t, w, &body)
+	redirect, err := url.Parse(body.RedirectTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := redirect.Query().Get("code")
+	if code == "" {
+		t.Fatalf("authorize: no code in %q", body.RedirectTo)
+	}
+	return code
+}
+
+// postForm calls an endpoint of the authorization server as the app.
+func postForm(t *testing.T, path string, app testApp, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(app.ClientID, app.Secret)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// exchangeCode redeems an authorization code at the token endpoint.
+func exchangeCode(t *testing.T, app testApp, code string, redirectURI string, verifier string) *httptest.ResponseRecorder {
+	t.Helper()
+	return postForm(t, "/api/oauth/token", app, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	})
+}
+
+// oauthErrorCode returns the RFC 6749 error code of a response.
+func oauthErrorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error string `json:"error"`
+	}
+	decode(t, w, &body)
+	return body.Error
+}
+
+// appToken returns the access token of a successful token response.
+func appToken(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("token: got %d %s, want 200", w.Code, w.Body)
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	decode(t, w, &body)
+	return body.AccessToken
+}
+
+// introspect reports whether the authorization server tells the app the token is active.
+func introspect(t *testing.T, app testApp, token string) bool {
+	t.Helper()
+	w := postForm(t, "/api/oauth/introspect", app, url.Values{"token": {token}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("introspect: got %d %s", w.Code, w.Body)
+	}
+	var body struct {
+		Active bool `json:"active"`
+	}
+	decode(t, w, &body)
+	return body.Active
+}
+
+func TestOAuthTokenExchangeChecksTheCode(t *testing.T) {
+	resetDB(t)
+	owner := signUp(t, "Owner", "")
+	alice := signUp(t, "Alice", "")
+	app := registerApp(t, owner, true)
+
+	if w := exchangeCode(t, app, authorizeApp(t, alice, app), appRedirectURI, "another-verifier-that-is-long-enough-for-pkce-0123456789"); w.Code != http.StatusBadRequest || oauthErrorCode(t, w) != "invalid_grant" {
+		t.Errorf("exchange with the wrong PKCE verifier: got %d %s, want 400 invalid_grant", w.Code, w.Body)
+	}
+	if w := exchangeCode(t, app, authorizeApp(t, alice, app), "https://app.example.com/other", appVerifier); w.Code != http.StatusBadRequest || oauthErrorCode(t, w) != "invalid_grant" {
+		t.Errorf("exchange with another redirect_uri: got %d %s, want 400 invalid_grant", w.Code, w.Body)
+	}
+
+	code := authorizeApp(t, alice, app)
+	token := appToken(t, exchangeCode(t, app, code, appRedirectURI, appVerifier))
+	if w := request(t, http.MethodGet, "/api/restaurants", token, nil); w.Code != http.StatusOK {
+		t.Fatalf("app token: got %d %s, want 200", w.Code, w.Body)
+	}
+	if w := exchangeCode(t, app, code, appRedirectURI, appVerifier); w.Code != http.StatusBadRequest || oauthErrorCode(t, w) != "invalid_grant" {
+		t.Errorf("reused code: got %d %s, want 400 invalid_grant", w.Code, w.Body)
+	}
+}
+
+func TestOAuthScopesAreLimitedToTheClient(t *testing.T) {
+	resetDB(t)
+	owner := signUp(t, "Owner", "")
+	app := registerApp(t, owner, true)
+
+	w := request(t, http.MethodGet, "/api/oauth/authorize?"+url.Values{
+		"response_type":         {"code"},
+		"client_id":             {app.ClientID},
+		"redirect_uri":          {appRedirectURI},
+		"scope":                 {models.PermissionRestaurantsWrite},
+		"code_challenge":        {helpers.PKCEChallenge(appVerifier)},
+		"code_challenge_method": {"S256"},
+	}.Encode(), owner.AccessToken, nil)
+	if w.Code != http.StatusBadRequest || oauthErrorCode(t, w) != "invalid_scope" {
+		t.Errorf("authorize a scope the client did not register: got %d %s, want 400 invalid_scope", w.Code, w.Body)
+	}
+
+	w = postForm(t, "/api/oauth/token", app, url.Values{"grant_type": {"client_credentials"}, "scope": {models.PermissionRestaurantsWrite}})
+	if w.Code != http.StatusBadRequest || oauthErrorCode(t, w) != "invalid_scope" {
+		t.Errorf("client_credentials for a scope the client did not register: got %d %s, want 400 invalid_scope", w.Code, w.Body)
+	}
+}
+
+func TestOAuthClientCredentialsRequireAConfidentialClient(t *testing.T) {
+	resetDB(t)
+	owner := signUp(t, "Owner", "")
+	public := registerApp(t, owner, false)
+	confidential := registerApp(t, owner, true)
+
+	w := postForm(t, "/api/oauth/token", public, url.Values{"grant_type": {"client_credentials"}})
+	if w.Code != http.StatusBadRequest || oauthErrorCode(t, w) != "unauthorized_client" {
+		t.Errorf("client_credentials for a public client: got %d %s, want 400 unauthorized_client", w.Code, w.Body)
+	}
+
+	wrongSecret := testApp{ClientID: confidential.ClientID, Secret: "not-the-secret"}
+	if w := postForm(t, "/api/oauth/token", wrongSecret, url.Values{"grant_type": {"client_credentials"}}); w.Code != http.StatusUnauthorized {
+		t.Errorf("client_credentials with a wrong secret: got %d %s, want 401", w.Code, w.Body)
+	}
+	appToken(t, postForm(t, "/api/oauth/token", confidential, url.Values{"grant_type": {"client_credentials"}}))
+}
+
+func TestOAuthIntrospectionOnlyReportsTheClientsOwnTokens(t *testing.T) {
+	resetDB(t)
+	owner := signUp(t, "Owner", "")
+	alice := signUp(t, "Alice", "")
+	app := registerApp(t, owner, true)
+	otherApp := registerApp(t, owner, true)
+
+	token := appToken(t, exchangeCode(t, app, authorizeApp(t, alice, app), appRedirectURI, appVerifier))
+	if !introspect(t, app, token) {
+		t.Fatal("the app's own token is reported inactive")
+	}
+	if introspect(t, otherApp, token) {
+		t.Error("another app's token is reported active")
+	}
+	if introspect(t, app, alice.AccessToken) {
+		t.Error("a session token is reported active")
+	}
+
+	// Another app cannot revoke the token, its own app can
+	if w := postForm(t, "/api/oauth/revoke", otherApp, url.Values{"token": {token}}); w.Code != http.StatusOK {
+		t.Fatalf("revoke by another app: got %d %s, want 200", w.Code, w.Body)
+	}
+	if !introspect(t, app, token) {
+		t.Fatal("another app revoked the token")
+	}
+	if w := postForm(t, "/api/oauth/revoke", app, url.Values{"token": {token}}); w.Code != http.StatusOK {
+		t.Fatalf("revoke: got %d %s, want 200", w.Code, w.Body)
+	}
+	if introspect(t, app, token) {
+		t.Error("revoked token is reported active")
+	}
+}
+
+func TestRevokingConsentInvalidatesTokens(t *testing.T) {
+	resetDB(t)
+	owner := signUp(t, "Owner", "")
+	alice := signUp(t, "Alice", "")
+	app := registerApp(t, owner, true)
+
+	token := appToken(t, exchangeCode(t, app, authorizeApp(t, alice, app), appRedirectURI, appVerifier))
+	if w := request(t, http.MethodDelete, "/api/me/oauth/consents/"+app.ClientID, alice.AccessToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("revoke consent: got %d %s, want 200", w.Code, w.Body)
+	}
+
+	if introspect(t, app, token) {
+		t.Error("token is reported active after the consent was revoked")
+	}
+	if w := request(t, http.MethodGet, "/api/restaurants", token, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("token after the consent was revoked: got %d %s, want 401", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetMe returns the profile of the signed-in user
+func GetMe(c *gin.Context) {
+	user, err := services.GetUserByID(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserView(user))
+}
+
+// UpdateMe updates the profile of the signed-in user
+func UpdateMe(c *gin.Context) {
+	var input dto.UpdateProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := services.UpdateProfile(c.GetString("user_id"), input.Name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserView(user))
+}
+
+// ChangePassword changes the password of the signed-in user
+func ChangePassword(c *gin.Context) {
+	var input dto.ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := services.ChangePassword(c.GetString("user_id"), c.GetString("session_id"), input.CurrentPassword, input.NewPassword)
+	if err != nil {
+		passwordErrorResponse(c, err, http.StatusBadRequest)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed, other sessions have been signed out"})
+}
+
+// ChangeEmail sends a confirmation link to the new email address
+func ChangeEmail(c *gin.Context) {
+	var input dto.ChangeEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.RequestEmailChange(c.GetString("user_id"), input.Email, input.CurrentPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "a confirmation link has been sent to the new address"})
+}
+
+// ConfirmEmailChange switches to the new email address with the token from the confirmation email
+func ConfirmEmailChange(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing token parameter"})
+		return
+	}
+
+	if err := services.ConfirmEmailChange(token); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email changed"})
+}
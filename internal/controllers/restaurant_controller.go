@@ -1,25 +1,65 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/alpha-154/crud-go-gin/internal/dto"
 	"github.com/alpha-154/crud-go-gin/internal/models"
 	"github.com/alpha-154/crud-go-gin/internal/services"
+	"github.com/alpha-154/crud-go-gin/internal/validation"
 	"github.com/gin-gonic/gin"
 )
 
+// currentActor returns the authenticated user and the permissions resolved for the request
+func currentActor(c *gin.Context) dto.Actor {
+	permissions, _ := c.Get("permissions")
+	granted, _ := permissions.([]string)
+	return dto.Actor{UserID: c.GetString("user_id"), Permissions: granted}
+}
+
+// restaurantErrorStatus maps restaurant service errors to HTTP status codes
+func restaurantErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrRestaurantNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidQuery), errors.Is(err, services.ErrInvalidLocation):
+		return http.StatusBadRequest
+	}
+	return fallback
+}
+
+// bindRestaurantJSON binds a restaurant payload, answering 422 with every invalid field
+// or 400 for a malformed body. It reports whether the handler may go on.
+func bindRestaurantJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+	if errs, ok := validation.FieldErrors(err); ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": errs})
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	return false
+}
+
 // CreateRestaurant handles the request to create a new restaurant
 func CreateRestaurant(c *gin.Context) {
 	var restaurant models.Restaurant
 
-	if err := c.ShouldBindJSON(&restaurant); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindRestaurantJSON(c, &restaurant) {
 		return
 	}
 
-	result, err := services.CreateRestaurant(restaurant)
+	result, err := services.CreateRestaurant(restaurant, c.GetString("user_id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(restaurantErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -27,15 +67,125 @@ func CreateRestaurant(c *gin.Context) {
 
 }
 
-// GetAllRestaurants retrieves all restaurants
+// ImportRestaurants creates a batch of restaurants owned by the signed-in user
+func ImportRestaurants(c *gin.Context) {
+	var input dto.ImportRestaurantsInput
+
+	if !bindRestaurantJSON(c, &input) {
+		return
+	}
+
+	result, err := services.ImportRestaurants(input.Restaurants, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(restaurantErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Restaurants imported successfully", "data": result})
+}
+
+// GetAllRestaurants retrieves one page of restaurants matching the filters
 func GetAllRestaurants(c *gin.Context) {
-	restaurants, err := services.GetAllRestaurants()
+	var query dto.RestaurantListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := services.GetAllRestaurants(query)
+	if err != nil {
+		c.JSON(restaurantErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	if links := paginationLinks(c, page); links != "" {
+		c.Header("Link", links)
+	}
+	if page.Total != nil {
+		c.Header("X-Total-Count", strconv.FormatInt(*page.Total, 10))
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// paginationLinks builds the Link header for the neighbouring pages. Requests paging by
+// offset get offset links, everything else follows the cursor.
+func paginationLinks(c *gin.Context, page *dto.RestaurantPage) string {
+	link := func(rel string, param string, value string) string {
+		u := *c.Request.URL
+		query := u.Query()
+		query.Del("cursor")
+		query.Del("offset")
+		if value != "" {
+			query.Set(param, value)
+		}
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf("<%s>; rel=\"%s\"", u.RequestURI(), rel)
+	}
+
+	links := []string{}
+	if c.Query("offset") != "" {
+		if page.HasMore {
+			links = append(links, link("next", "offset", strconv.Itoa(page.Offset+page.Limit)))
+		}
+		if page.Offset > 0 {
+			links = append(links, link("prev", "offset", strconv.Itoa(max(page.Offset-page.Limit, 0))))
+			links = append(links, link("first", "offset", "0"))
+		}
+	} else if page.NextCursor != "" {
+		links = append(links, link("next", "cursor", page.NextCursor))
+	}
+	return strings.Join(links, ", ")
+}
+
+// SearchRestaurants runs a full-text search over name, cuisine and address
+func SearchRestaurants(c *gin.Context) {
+	var query dto.RestaurantSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := services.SearchRestaurants(query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, restaurants)
+	c.JSON(http.StatusOK, results)
+}
+
+// GetNearbyRestaurants lists the restaurants closest to a point with their distance
+func GetNearbyRestaurants(c *gin.Context) {
+	var query dto.NearbyRestaurantsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := services.FindRestaurantsNear(query)
+	if err != nil {
+		c.JSON(restaurantErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
+
+// GetRestaurantsWithin lists the restaurants inside a bounding box or polygon
+func GetRestaurantsWithin(c *gin.Context) {
+	var query dto.RestaurantsWithinQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := services.FindRestaurantsWithin(query)
+	if err != nil {
+		c.JSON(restaurantErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
 }
 
 // GetRestaurant retrieves a restaurant by ID
@@ -60,14 +210,13 @@ func UpdateRestaurant(c *gin.Context) {
 	id := c.Param("id")
 	var updatedRestaurant models.Restaurant
 
-	if err := c.ShouldBindJSON(&updatedRestaurant); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindRestaurantJSON(c, &updatedRestaurant) {
 		return
 	}
 
-	result, err := services.UpdateRestaurant(id, updatedRestaurant)
+	result, err := services.UpdateRestaurant(id, updatedRestaurant, currentActor(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(restaurantErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -77,11 +226,67 @@ func UpdateRestaurant(c *gin.Context) {
 // DeleteRestaurant removes a restaurant by ID
 func DeleteRestaurant(c *gin.Context) {
 	id := c.Param("id")
-	result, err := services.DeleteRestaurant(id)
+	result, err := services.DeleteRestaurant(id, currentActor(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(restaurantErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Restaurant deleted successfully", "data": result})
 }
+
+// GetMyRestaurants lists the restaurants the signed-in user owns or manages
+func GetMyRestaurants(c *gin.Context) {
+	restaurants, err := services.GetRestaurantsForUser(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, restaurants)
+}
+
+// AddRestaurantManager lets another user manage a restaurant
+func AddRestaurantManager(c *gin.Context) {
+	var input dto.ManagerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := services.AddRestaurantManager(c.Param("id"), input.UserID, currentActor(c))
+	if err != nil {
+		c.JSON(restaurantErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "manager added"})
+}
+
+// RemoveRestaurantManager revokes a user's right to manage a restaurant
+func RemoveRestaurantManager(c *gin.Context) {
+	err := services.RemoveRestaurantManager(c.Param("id"), c.Param("user_id"), currentActor(c))
+	if err != nil {
+		c.JSON(restaurantErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "manager removed"})
+}
+
+// TransferRestaurantOwnership hands a restaurant over to another user
+func TransferRestaurantOwnership(c *gin.Context) {
+	var input dto.TransferOwnershipInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := services.TransferRestaurantOwnership(c.Param("id"), input.NewOwnerID, currentActor(c))
+	if err != nil {
+		c.JSON(restaurantErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ownership transferred"})
+}
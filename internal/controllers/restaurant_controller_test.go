@@ -0,0 +1,169 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	bsonv2 "go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// insertUnownedRestaurant stores a restaurant the way it was stored before ownership existed.
+func insertUnownedRestaurant(t *testing.T, id string) {
+	t.Helper()
+	_, err := config.GetCollection(config.DB, "restaurants").InsertOne(context.Background(), bson.M{
+		"_id":           primitive.NewObjectID(),
+		"restaurant_id": id,
+		"name":          "Old Place",
+		"address":       "1 Market St, Springfield",
+		"email":         "old@example.com",
+		"cuisine":       "italian",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// restaurantOwner returns the stored owner_id of a restaurant, or "" when it has none.
+func restaurantOwner(t *testing.T, id string) string {
+	t.Helper()
+	for _, doc := range db.Documents(t, "restaurants") {
+		var restaurant struct {
+			RestaurantID string `bson:"restaurant_id"`
+			OwnerID      string `bson:"owner_id"`
+		}
+		raw, _ := bsonv2.Marshal(doc)
+		if err := bsonv2.Unmarshal(raw, &restaurant); err != nil {
+			t.Fatal(err)
+		}
+		if restaurant.RestaurantID == id {
+			return restaurant.OwnerID
+		}
+	}
+	t.Fatalf("restaurant %s not found", id)
+	return ""
+}
+
+func TestAdminAssignsOwnerToUnownedRestaurant(t *testing.T) {
+	resetDB(t)
+	admin := signUp(t, "Admin", models.RoleAdmin)
+	alice := signUp(t, "Alice", "")
+	insertUnownedRestaurant(t, "legacy")
+
+	w := request(t, http.MethodPost, "/api/restaurants/legacy/transfer", alice.AccessToken, map[string]string{"new_owner_id": alice.ID})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("transfer by a plain user: got %d %s, want 403", w.Code, w.Body)
+	}
+
+	w = request(t, http.MethodPost, "/api/restaurants/legacy/transfer", admin.AccessToken, map[string]string{"new_owner_id": alice.ID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("transfer by an admin: got %d %s", w.Code, w.Body)
+	}
+	if owner := restaurantOwner(t, "legacy"); owner != alice.ID {
+		t.Fatalf("owner_id = %q, want %q", owner, alice.ID)
+	}
+}
+
+func TestBackfillRestaurantOwners(t *testing.T) {
+	resetDB(t)
+	alice := signUp(t, "Alice", "")
+	insertUnownedRestaurant(t, "legacy")
+
+	if err := services.BackfillRestaurantOwners(); err != nil {
+		t.Fatal(err)
+	}
+	if owner := restaurantOwner(t, "legacy"); owner != "" {
+		t.Fatalf("owner assigned without RESTAURANT_BACKFILL_OWNER: %q", owner)
+	}
+
+	os.Setenv("RESTAURANT_BACKFILL_OWNER", alice.Email)
+	defer os.Unsetenv("RESTAURANT_BACKFILL_OWNER")
+	if err := services.BackfillRestaurantOwners(); err != nil {
+		t.Fatal(err)
+	}
+	if owner := restaurantOwner(t, "legacy"); owner != alice.ID {
+		t.Fatalf("owner_id = %q, want %q", owner, alice.ID)
+	}
+}
+
+func TestRestaurantAccessChangesRequireVerifiedEmail(t *testing.T) {
+	resetDB(t)
+	os.Setenv("REQUIRE_EMAIL_VERIFICATION", "writes")
+	defer os.Unsetenv("REQUIRE_EMAIL_VERIFICATION")
+	alice := signUp(t, "Alice", "")
+	bob := signUp(t, "Bob", "")
+	insertUnownedRestaurant(t, "place")
+	_, err := config.GetCollection(config.DB, "restaurants").UpdateOne(
+		context.Background(),
+		bson.M{"restaurant_id": "place"},
+		bson.M{"$set": bson.M{"owner_id": alice.ID, "manager_ids": bson.A{}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writes := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"add manager", http.MethodPost, "/api/restaurants/place/managers", map[string]string{"user_id": bob.ID}},
+		{"remove manager", http.MethodDelete, "/api/restaurants/place/managers/" + bob.ID, nil},
+		{"transfer", http.MethodPost, "/api/restaurants/place/transfer", map[string]string{"new_owner_id": bob.ID}},
+	}
+	for _, write := range writes {
+		if w := request(t, write.method, write.path, alice.AccessToken, write.body); w.Code != http.StatusForbidden {
+			t.Errorf("%s with an unverified email: got %d %s, want 403", write.name, w.Code, w.Body)
+		}
+	}
+
+	verifyEmail(t, alice.ID)
+	for _, write := range writes {
+		if w := request(t, write.method, write.path, alice.AccessToken, write.body); w.Code != http.StatusOK {
+			t.Errorf("%s with a verified email: got %d %s, want 200", write.name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestCreateRestaurantValidatesCustomRules(t *testing.T) {
+	resetDB(t)
+	alice := signUp(t, "Alice", "")
+	verifyEmail(t, alice.ID)
+
+	// The rules are registered by the validation package itself, nothing in TestMain sets them up
+	w := request(t, http.MethodPost, "/api/restaurants", alice.AccessToken, map[string]string{
+		"name": "Place", "address": "x", "email": "place@example.com", "cuisine": "martian",
+	})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("create with an invalid cuisine and address: got %d %s, want 422", w.Code, w.Body)
+	}
+	var body struct {
+		Errors []struct {
+			Field string `json:"field"`
+			Code  string `json:"code"`
+		} `json:"errors"`
+	}
+	decode(t, w, &body)
+	codes := map[string]string{}
+	for _, e := range body.Errors {
+		codes[e.Field] = e.Code
+	}
+	if codes["cuisine"] != "cuisine" || codes["address"] != "address" {
+		t.Fatalf("field errors = %+v, want cuisine and address", body.Errors)
+	}
+
+	w = request(t, http.MethodPost, "/api/restaurants", alice.AccessToken, map[string]string{
+		"name": "Place", "address": "1 Market St, Springfield", "email": "place@example.com", "cuisine": "Italian",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s, want 201", w.Code, w.Body)
+	}
+}
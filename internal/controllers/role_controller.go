@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// roleErrorStatus maps refused role changes to 403, and other errors to the fallback status
+func roleErrorStatus(err error, fallback int) int {
+	if errors.Is(err, services.ErrInsufficientPermissions) || errors.Is(err, services.ErrOwnRole) {
+		return http.StatusForbidden
+	}
+	return fallback
+}
+
+// GetRoles lists every role and its permissions
+func GetRoles(c *gin.Context) {
+	roles, err := services.GetRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole defines a new role
+func CreateRole(c *gin.Context) {
+	var input dto.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := services.CreateRole(input, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(roleErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole changes the permissions of a role
+func UpdateRole(c *gin.Context) {
+	var input dto.UpdateRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.UpdateRole(c.Param("name"), input, c.GetString("user_id")); err != nil {
+		c.JSON(roleErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role updated"})
+}
+
+// DeleteRole removes an unused role
+func DeleteRole(c *gin.Context) {
+	if err := services.DeleteRole(c.Param("name")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role deleted"})
+}
@@ -0,0 +1,50 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alpha-154/crud-go-gin/internal/models"
+)
+
+func TestRoleChangesRequireTheirPermissions(t *testing.T) {
+	resetDB(t)
+	createRole(t, "role-admin", models.PermissionRolesManage, models.PermissionUsersWrite, models.PermissionRestaurantsRead, models.PermissionRestaurantsWrite)
+	createRole(t, "support", models.PermissionUsersImpersonate, models.PermissionRestaurantsRead)
+	createRole(t, "editor", models.PermissionRestaurantsRead)
+	roleAdmin := signUp(t, "RoleAdmin", "role-admin")
+	admin := signUp(t, "Admin", models.RoleAdmin)
+
+	role := func(name string, permissions ...string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "permissions": permissions}
+	}
+	refused := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"create a role with every permission", http.MethodPost, "/api/roles", role("root", models.PermissionAll)},
+		{"create a role that impersonates", http.MethodPost, "/api/roles", role("spy", models.PermissionUsersImpersonate)},
+		{"widen their own role", http.MethodPut, "/api/roles/role-admin", role("", models.PermissionRolesManage, models.PermissionAll)},
+		{"change their own role", http.MethodPut, "/api/roles/role-admin", role("", models.PermissionRolesManage)},
+		{"grant a permission they lack", http.MethodPut, "/api/roles/editor", role("", models.PermissionRestaurantsRead, models.PermissionUsersImpersonate)},
+		{"change a role with a permission they lack", http.MethodPut, "/api/roles/support", role("", models.PermissionRestaurantsRead)},
+		{"change the admin role", http.MethodPut, "/api/roles/admin", role("", models.PermissionAll)},
+	}
+	for _, r := range refused {
+		if w := request(t, r.method, r.path, roleAdmin.AccessToken, r.body); w.Code != http.StatusForbidden {
+			t.Errorf("%s: got %d %s, want 403", r.name, w.Code, w.Body)
+		}
+	}
+
+	if w := request(t, http.MethodPost, "/api/roles", roleAdmin.AccessToken, role("reviewer", models.PermissionRestaurantsRead)); w.Code != http.StatusCreated {
+		t.Fatalf("create a role within their permissions: got %d %s, want 201", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodPut, "/api/roles/editor", roleAdmin.AccessToken, role("", models.PermissionRestaurantsRead, models.PermissionRestaurantsWrite)); w.Code != http.StatusOK {
+		t.Fatalf("update a role within their permissions: got %d %s, want 200", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodPut, "/api/roles/support", admin.AccessToken, role("", models.PermissionRestaurantsRead)); w.Code != http.StatusOK {
+		t.Fatalf("update a role as an admin: got %d %s, want 200", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/alpha-154/crud-go-gin/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSessions lists the active sessions of the signed-in user
+func GetSessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	sessions, err := services.GetUserSessions(userID, c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession signs the user out of one of their sessions
+func RevokeSession(c *gin.Context) {
+	userID := c.GetString("user_id")
+	sessionID := c.Param("id")
+
+	if err := services.RevokeSession(userID, sessionID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Clear the cookie if the current session was revoked
+	if sessionID == c.GetString("session_id") {
+		c.SetCookie("refresh_token", "", -1, "/", "", false, true)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+// RevokeAllSessions signs the user out everywhere
+func RevokeAllSessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	if err := services.RevokeAllSessions(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "logout failed"})
+		return
+	}
+
+	c.SetCookie("refresh_token", "", -1, "/", "", false, true)
+	c.JSON(http.StatusOK, gin.H{"message": "logged out from all sessions"})
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// userErrorStatus maps user service errors to HTTP status codes.
+func userErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrInsufficientPermissions):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrUserOwnsRestaurants):
+		return http.StatusConflict
+	}
+	return fallback
+}
+
+// CreateUser creates an account on behalf of an admin
+func CreateUser(c *gin.Context) {
+	var input dto.CreateUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := services.CreateUser(input, c.GetString("user_id"))
+	if err != nil {
+		passwordErrorResponse(c, err, userErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewUserView(user))
+}
+
+// UpdateUser changes the name or email of a user
+func UpdateUser(c *gin.Context) {
+	var input dto.UpdateUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := services.UpdateUser(c.Param("id"), input, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(userErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserView(user))
+}
+
+// DisableUser blocks a user from signing in and revokes their sessions
+func DisableUser(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == c.GetString("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot disable your own account"})
+		return
+	}
+
+	if err := services.SetUserDisabled(userID, true, c.GetString("user_id")); err != nil {
+		c.JSON(userErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user disabled"})
+}
+
+// EnableUser lets a disabled user sign in again
+func EnableUser(c *gin.Context) {
+	if err := services.SetUserDisabled(c.Param("id"), false, c.GetString("user_id")); err != nil {
+		c.JSON(userErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user enabled"})
+}
+
+// DeleteUser removes a user account
+func DeleteUser(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == c.GetString("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot delete your own account"})
+		return
+	}
+
+	if err := services.DeleteUser(userID, c.GetString("user_id")); err != nil {
+		c.JSON(userErrorStatus(err, http.StatusNotFound), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
+}
+
+// SetUserPassword replaces the password of a user
+func SetUserPassword(c *gin.Context) {
+	var input dto.SetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.AdminSetPassword(c.Param("id"), input.Password, c.GetString("user_id")); err != nil {
+		passwordErrorResponse(c, err, userErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password updated"})
+}
+
+// GetUserSessions lists the active sessions of a user
+func GetUserSessions(c *gin.Context) {
+	sessions, err := services.GetUserSessions(c.Param("id"), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// ImpersonateUser returns a short-lived token for acting as the user
+func ImpersonateUser(c *gin.Context) {
+	var input dto.ImpersonateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.Param("id")
+	if userID == c.GetString("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot impersonate yourself"})
+		return
+	}
+
+	token, err := services.ImpersonateUser(c.GetString("user_id"), userID, input.Reason)
+	if err != nil {
+		c.JSON(userErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
@@ -0,0 +1,262 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+	"github.com/alpha-154/crud-go-gin/internal/services"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createRole defines a custom role granting the permissions.
+func createRole(t *testing.T, name string, permissions ...string) {
+	t.Helper()
+	_, err := config.GetCollection(config.DB, "roles").InsertOne(context.Background(), models.Role{
+		ID:          primitive.NewObjectID(),
+		Name:        name,
+		Permissions: permissions,
+	})
+	if err != nil {
+		t.Fatal("create role:", err)
+	}
+}
+
+func TestCreateUserWithRoleRequiresItsPermissions(t *testing.T) {
+	resetDB(t)
+	createRole(t, "support", models.PermissionUsersWrite)
+	createRole(t, "role-admin", models.PermissionUsersWrite, models.PermissionRolesManage)
+	support := signUp(t, "Support", "support")
+	roleAdmin := signUp(t, "RoleAdmin", "role-admin")
+
+	newUser := func(email string, role string) map[string]string {
+		return map[string]string{"name": "New", "email": email, "password": testPassword, "role": role}
+	}
+
+	if w := request(t, http.MethodPost, "/api/users", support.AccessToken, newUser("plain@example.com", "")); w.Code != http.StatusCreated {
+		t.Fatalf("create with the default role: got %d %s, want 201", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodPost, "/api/users", support.AccessToken, newUser("admin1@example.com", models.RoleAdmin)); w.Code != http.StatusForbidden {
+		t.Fatalf("create an admin without roles:manage: got %d %s, want 403", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodPost, "/api/users", support.AccessToken, newUser("support2@example.com", "support")); w.Code != http.StatusForbidden {
+		t.Fatalf("assign a role without roles:manage: got %d %s, want 403", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodPost, "/api/users", roleAdmin.AccessToken, newUser("admin2@example.com", models.RoleAdmin)); w.Code != http.StatusForbidden {
+		t.Fatalf("create an admin with fewer permissions: got %d %s, want 403", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodPost, "/api/users", roleAdmin.AccessToken, newUser("support3@example.com", "support")); w.Code != http.StatusCreated {
+		t.Fatalf("assign a role within the caller's permissions: got %d %s, want 201", w.Code, w.Body)
+	}
+}
+
+// userRole returns the stored role of a user.
+func userRole(t *testing.T, userID string) string {
+	t.Helper()
+	var user models.User
+	if err := config.GetCollection(config.DB, "users").FindOne(context.Background(), bson.M{"user_id": userID}).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	return user.Role
+}
+
+func TestSetUserRoleRequiresPermissionsOfBothRoles(t *testing.T) {
+	resetDB(t)
+	createRole(t, "role-admin", models.PermissionRolesManage, models.PermissionUsersWrite, models.PermissionRestaurantsRead, models.PermissionRestaurantsWrite)
+	createRole(t, "editor", models.PermissionRestaurantsRead)
+	roleAdmin := signUp(t, "RoleAdmin", "role-admin")
+	admin := signUp(t, "Admin", models.RoleAdmin)
+	alice := signUp(t, "Alice", "")
+
+	setRole := func(user testUser, role string) int {
+		return request(t, http.MethodPut, "/api/users/"+user.ID+"/role", roleAdmin.AccessToken, map[string]string{"role": role}).Code
+	}
+
+	// Promoting an account to a role with more permissions than the caller
+	if code := setRole(alice, models.RoleAdmin); code != http.StatusForbidden {
+		t.Errorf("promote to admin: got %d, want 403", code)
+	}
+	if role := userRole(t, alice.ID); role != models.RoleUser {
+		t.Errorf("refused promotion changed the role to %q", role)
+	}
+	// Demoting a user who holds more permissions than the caller
+	if code := setRole(admin, models.RoleUser); code != http.StatusForbidden {
+		t.Errorf("demote an admin: got %d, want 403", code)
+	}
+	if role := userRole(t, admin.ID); role != models.RoleAdmin {
+		t.Errorf("refused demotion changed the role to %q", role)
+	}
+
+	if code := setRole(alice, "editor"); code != http.StatusOK {
+		t.Fatalf("assign a role within the caller's permissions: got %d, want 200", code)
+	}
+	if role := userRole(t, alice.ID); role != "editor" {
+		t.Fatalf("role = %q, want editor", role)
+	}
+}
+
+func TestAdminActionsRequireOutrankingTheTarget(t *testing.T) {
+	resetDB(t)
+	createRole(t, "support", models.PermissionUsersWrite, models.PermissionRestaurantsRead, models.PermissionRestaurantsWrite)
+	support := signUp(t, "Support", "support")
+	admin := signUp(t, "Admin", models.RoleAdmin)
+	alice := signUp(t, "Alice", "")
+
+	actions := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"update", http.MethodPut, "/update", map[string]string{"email": "taken-over@example.com"}},
+		{"set password", http.MethodPost, "/password", map[string]string{"password": "Another-Horse-7"}},
+		{"disable", http.MethodPost, "/disable", nil},
+		{"enable", http.MethodPost, "/enable", nil},
+		{"log out", http.MethodPost, "/logout", nil},
+		{"unlock", http.MethodPost, "/unlock", nil},
+		{"delete", http.MethodDelete, "", nil},
+	}
+	for _, action := range actions {
+		path := "/api/users/" + admin.ID + action.path
+		if action.path == "/update" {
+			path = "/api/users/" + admin.ID
+		}
+		if w := request(t, action.method, path, support.AccessToken, action.body); w.Code != http.StatusForbidden {
+			t.Errorf("%s an admin as support: got %d %s, want 403", action.name, w.Code, w.Body)
+		}
+	}
+
+	for _, action := range []string{"/unlock", "/logout", "/disable"} {
+		if w := request(t, http.MethodPost, "/api/users/"+alice.ID+action, support.AccessToken, nil); w.Code != http.StatusOK {
+			t.Fatalf("%s a plain user as support: got %d %s, want 200", action, w.Code, w.Body)
+		}
+	}
+	if w := request(t, http.MethodDelete, "/api/users/"+support.ID, admin.AccessToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete support as an admin: got %d %s, want 200", w.Code, w.Body)
+	}
+}
+
+func TestDeleteUserRemovesCredentialsAndGrants(t *testing.T) {
+	resetDB(t)
+	admin := signUp(t, "Admin", models.RoleAdmin)
+	alice := signUp(t, "Alice", "")
+
+	if _, err := services.CreateAPIKey(alice.ID, dto.CreateAPIKeyInput{Name: "ci", Scopes: []string{models.PermissionRestaurantsRead}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := services.RegisterOAuthClient(alice.ID, dto.RegisterOAuthClientInput{
+		Name:         "app",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Scopes:       []string{models.PermissionRestaurantsRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restaurants := config.GetCollection(config.DB, "restaurants")
+	_, err = config.GetCollection(config.DB, "external_identities").InsertOne(context.Background(), bson.M{
+		"_id": primitive.NewObjectID(), "provider": "google", "subject": "123", "user_id": alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	insertUnownedRestaurant(t, "owned")
+	insertUnownedRestaurant(t, "managed")
+	if _, err := restaurants.UpdateOne(context.Background(), bson.M{"restaurant_id": "owned"}, bson.M{"$set": bson.M{"owner_id": alice.ID}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restaurants.UpdateOne(context.Background(), bson.M{"restaurant_id": "managed"}, bson.M{"$set": bson.M{"manager_ids": bson.A{alice.ID}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := request(t, http.MethodDelete, "/api/users/"+alice.ID, admin.AccessToken, nil); w.Code != http.StatusConflict {
+		t.Fatalf("delete a restaurant owner: got %d %s, want 409", w.Code, w.Body)
+	}
+	if n := countWhere(t, "users", "user_id", alice.ID); n != 1 {
+		t.Fatalf("refused delete removed the user")
+	}
+
+	if _, err := restaurants.UpdateOne(context.Background(), bson.M{"restaurant_id": "owned"}, bson.M{"$set": bson.M{"owner_id": admin.ID}}); err != nil {
+		t.Fatal(err)
+	}
+	if w := request(t, http.MethodDelete, "/api/users/"+alice.ID, admin.AccessToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: got %d %s, want 200", w.Code, w.Body)
+	}
+
+	for _, c := range []struct{ collection, field string }{
+		{"users", "user_id"},
+		{"api_keys", "user_id"},
+		{"oauth_clients", "owner_id"},
+		{"external_identities", "user_id"},
+		{"user_tokens", "user_id"},
+	} {
+		if n := countWhere(t, c.collection, c.field, alice.ID); n != 0 {
+			t.Errorf("%d documents left in %s", n, c.collection)
+		}
+	}
+	for _, doc := range db.Documents(t, "restaurants") {
+		for _, e := range doc {
+			if e.Key == "manager_ids" && fmt.Sprint(e.Value) != "[]" {
+				t.Errorf("manager_ids left: %v", e.Value)
+			}
+		}
+	}
+	if w := request(t, http.MethodGet, "/api/me", alice.AccessToken, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("deleted user's token: got %d, want 401", w.Code)
+	}
+}
+
+// impersonate asks for an impersonation token and returns the response.
+func impersonate(t *testing.T, actor testUser, target testUser) *httptest.ResponseRecorder {
+	t.Helper()
+	return request(t, http.MethodPost, "/api/users/"+target.ID+"/impersonate", actor.AccessToken, map[string]string{"reason": "support ticket"})
+}
+
+func TestImpersonationRequiresOutrankingTheTarget(t *testing.T) {
+	resetDB(t)
+	createRole(t, "support", models.PermissionUsersImpersonate, models.PermissionRestaurantsRead, models.PermissionRestaurantsWrite)
+	createRole(t, "user-admin", models.PermissionUsersWrite, models.PermissionRestaurantsRead, models.PermissionRestaurantsWrite)
+	support := signUp(t, "Support", "support")
+	userAdmin := signUp(t, "UserAdmin", "user-admin")
+	alice := signUp(t, "Alice", "")
+
+	if w := impersonate(t, support, userAdmin); w.Code != http.StatusForbidden {
+		t.Fatalf("impersonate a user with more permissions: got %d %s, want 403", w.Code, w.Body)
+	}
+	if w := impersonate(t, support, alice); w.Code != http.StatusOK {
+		t.Fatalf("impersonate a plain user: got %d %s, want 200", w.Code, w.Body)
+	}
+}
+
+func TestImpersonationCannotUseAdminRoutes(t *testing.T) {
+	resetDB(t)
+	createRole(t, "user-admin", models.PermissionUsersWrite, models.PermissionRestaurantsRead, models.PermissionRestaurantsWrite)
+	admin := signUp(t, "Admin", models.RoleAdmin)
+	userAdmin := signUp(t, "UserAdmin", "user-admin")
+	alice := signUp(t, "Alice", "")
+
+	w := impersonate(t, admin, userAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("impersonate: got %d %s", w.Code, w.Body)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	decode(t, w, &token)
+
+	if w := request(t, http.MethodGet, "/api/me", token.AccessToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("impersonation token: got %d %s, want 200", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodPost, "/api/users/"+alice.ID+"/disable", token.AccessToken, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("disable a user while impersonating: got %d %s, want 403", w.Code, w.Body)
+	}
+	if w := request(t, http.MethodPost, "/api/users/"+alice.ID+"/password", token.AccessToken, map[string]string{"password": "Another-Horse-7"}); w.Code != http.StatusForbidden {
+		t.Fatalf("set a password while impersonating: got %d %s, want 403", w.Code, w.Body)
+	}
+}
@@ -1,16 +1,311 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/models"
+)
+
+// SignUpInput is what a client may set when registering; the role is always assigned by the server
+type SignUpInput struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type SetRoleInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type RoleInput struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+type UpdateRoleInput struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
 type SignInInput struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	Device   string `json:"device"`
+}
+
+// ClientInfo describes the device a request comes from
+type ClientInfo struct {
+	Device    string
+	UserAgent string
+	IP        string
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ResendVerificationInput struct {
+	Email string `json:"email" binding:"required,email"`
 }
 
 type SignInServiceResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+
+	// Set instead of the tokens when the user has two-factor authentication enabled
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+type MFACodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAVerifyInput struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	Device   string `json:"device"`
+}
+
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCodePNG  []byte `json:"qr_code_png"` // base64 encoded in JSON
 }
 
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+
+	// Server-side metadata of the tokens, never sent to clients
+	AccessJTI        string    `json:"-"`
+	AccessExpiresAt  time.Time `json:"-"`
+	RefreshJTI       string    `json:"-"`
+	FamilyID         string    `json:"-"`
+	RefreshExpiresAt time.Time `json:"-"`
+}
+
+type SessionView struct {
+	SessionID  string    `json:"session_id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+// Actor is the authenticated user performing an operation
+type Actor struct {
+	UserID      string
+	Permissions []string
+}
+
+// ImportRestaurantsInput creates many restaurants at once; every entry is validated
+// like a single restaurant and nothing is stored unless all of them are valid
+type ImportRestaurantsInput struct {
+	Restaurants []models.Restaurant `json:"restaurants" binding:"required,min=1,max=1000,dive"`
+}
+
+type TransferOwnershipInput struct {
+	NewOwnerID string `json:"new_owner_id" binding:"required"`
+}
+
+type ManagerInput struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+type CreateUserInput struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role"`
+}
+
+type UpdateUserInput struct {
+	Name  string `json:"name"`
+	Email string `json:"email" binding:"omitempty,email"`
+}
+
+type SetPasswordInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// UserSearchQuery filters the user list; empty fields are ignored
+type UserSearchQuery struct {
+	Query    string `form:"q"`
+	Role     string `form:"role"`
+	Disabled *bool  `form:"disabled"`
+}
+
+type UpdateProfileInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type ChangeEmailInput struct {
+	Email           string `json:"email" binding:"required,email"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+type CreateAPIKeyInput struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
+
+// CreatedAPIKey is returned once when a key is created; the key cannot be retrieved again
+type CreatedAPIKey struct {
+	Key    string         `json:"key"`
+	APIKey *models.APIKey `json:"api_key"`
+}
+
+type RegisterOAuthClientInput struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,dive,url"`
+	Scopes       []string `json:"scopes" binding:"required,min=1"`
+	Confidential bool     `json:"confidential"`
+}
+
+// RegisteredOAuthClient is returned once on registration; the secret cannot be retrieved again
+type RegisteredOAuthClient struct {
+	ClientSecret string              `json:"client_secret,omitempty"`
+	Client       *models.OAuthClient `json:"client"`
+}
+
+// AuthorizeRequest holds the parameters of an OAuth authorization request
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+type ConsentInput struct {
+	AuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// AuthorizeResponse either asks for consent or tells the front end where to send the user
+type AuthorizeResponse struct {
+	ConsentRequired bool     `json:"consent_required,omitempty"`
+	ClientName      string   `json:"client_name,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
+	RedirectTo      string   `json:"redirect_to,omitempty"`
+}
+
+// OAuthTokenRequest is the form posted to the token endpoint
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// IntrospectionResponse follows RFC 7662; inactive tokens only carry Active
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+}
+
+type ImpersonateInput struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type ImpersonationToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// RestaurantListQuery holds the filters and paging options of GET /api/restaurants.
+// Pages are addressed either by Cursor (keyset) or by Offset.
+type RestaurantListQuery struct {
+	Cuisine      string `form:"cuisine"` // comma separated, exact match
+	Name         string `form:"name"`    // case-insensitive substring
+	Address      string `form:"address"` // case-insensitive substring
+	Sort         string `form:"sort"`    // e.g. "cuisine,-name"
+	Limit        int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset       int    `form:"offset" binding:"omitempty,min=0"`
+	Cursor       string `form:"cursor"`
+	IncludeTotal bool   `form:"include_total"`
+}
+
+type RestaurantSearchQuery struct {
+	Q     string `form:"q" binding:"required"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// RestaurantSearchResult is a match with its relevance and the matched text per field
+type RestaurantSearchResult struct {
+	Restaurant models.Restaurant `json:"restaurant"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// RestaurantSearchResponse tells whether the results come from the text index ("text")
+// or the typo-tolerant fallback ("fuzzy")
+type RestaurantSearchResponse struct {
+	Query   string                   `json:"query"`
+	Mode    string                   `json:"mode"`
+	Results []RestaurantSearchResult `json:"results"`
+}
+
+type RestaurantPage struct {
+	Data       []models.Restaurant `json:"data"`
+	Limit      int                 `json:"limit"`
+	Offset     int                 `json:"offset,omitempty"`
+	HasMore    bool                `json:"has_more"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+	Total      *int64              `json:"total,omitempty"`
+}
+
+// NearbyRestaurantsQuery finds restaurants around a point, closest first
+type NearbyRestaurantsQuery struct {
+	Lat         *float64 `form:"lat" binding:"required,min=-90,max=90"`
+	Lng         *float64 `form:"lng" binding:"required,min=-180,max=180"`
+	MaxDistance float64  `form:"max_distance" binding:"omitempty,gt=0"` // meters
+	Limit       int      `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// RestaurantsWithinQuery finds restaurants inside a bounding box ("minLng,minLat,maxLng,maxLat")
+// or a polygon ("lng,lat;lng,lat;..."). Distances are included when Lat and Lng are given.
+type RestaurantsWithinQuery struct {
+	BBox    string   `form:"bbox"`
+	Polygon string   `form:"polygon"`
+	Lat     *float64 `form:"lat" binding:"omitempty,min=-90,max=90"`
+	Lng     *float64 `form:"lng" binding:"omitempty,min=-180,max=180"`
+	Limit   int      `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type GeoRestaurantResult struct {
+	Restaurant     models.Restaurant `json:"restaurant"`
+	DistanceMeters *float64          `json:"distance_meters,omitempty"`
 }
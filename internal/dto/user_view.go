@@ -0,0 +1,51 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/models"
+)
+
+// UserView is the public representation of a user returned by the API.
+// It never carries the password hash, MFA secrets or any token.
+type UserView struct {
+	UserID          string     `json:"user_id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	PendingEmail    string     `json:"pending_email,omitempty"`
+	MFAEnabled      bool       `json:"mfa_enabled"`
+	Disabled        bool       `json:"disabled"`
+	DisabledAt      *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// NewUserView builds the public view of a stored user.
+func NewUserView(user *models.User) UserView {
+	return UserView{
+		UserID:          user.UserID,
+		Name:            user.Name,
+		Email:           user.Email,
+		Role:            user.Role,
+		EmailVerified:   user.EmailVerified,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		PendingEmail:    user.PendingEmail,
+		MFAEnabled:      user.MFAEnabled,
+		Disabled:        user.Disabled,
+		DisabledAt:      user.DisabledAt,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
+
+// NewUserViews builds the public views of a list of users.
+func NewUserViews(users []models.User) []UserView {
+	views := make([]UserView, 0, len(users))
+	for i := range users {
+		views = append(views, NewUserView(&users[i]))
+	}
+	return views
+}
@@ -0,0 +1,77 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FileGeocoder looks addresses up in a CSV gazetteer with "address,lat,lng" rows.
+// An exact match wins; otherwise the longest gazetteer entry contained in the
+// address is used, so a row per city or street gives approximate positions.
+type FileGeocoder struct {
+	entries map[string]Result
+}
+
+// NewFileGeocoder loads the gazetteer. A header row is skipped.
+func NewFileGeocoder(path string) (*FileGeocoder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	g := &FileGeocoder{entries: make(map[string]Result)}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		lng, lngErr := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if latErr != nil || lngErr != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: invalid coordinates", path, line)
+		}
+		g.entries[normalizeAddress(record[0])] = Result{Lat: lat, Lng: lng}
+	}
+	return g, nil
+}
+
+func (g *FileGeocoder) Geocode(ctx context.Context, address string) (*Result, error) {
+	normalized := normalizeAddress(address)
+	if normalized == "" {
+		return nil, ErrNotFound
+	}
+	if result, ok := g.entries[normalized]; ok {
+		return &result, nil
+	}
+
+	// Match whole words only, so "rome" does not match "romeo street"
+	padded := " " + normalized + " "
+	best := ""
+	for entry := range g.entries {
+		if len(entry) > len(best) && strings.Contains(padded, " "+entry+" ") {
+			best = entry
+		}
+	}
+	if best == "" {
+		return nil, ErrNotFound
+	}
+	result := g.entries[best]
+	return &result, nil
+}
@@ -0,0 +1,72 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const gazetteer = `address,lat,lng
+"1 Market St, Springfield",39.80,-89.64
+Springfield,39.78,-89.65
+Market St Springfield,39.79,-89.66
+Rome,41.90,12.50
+`
+
+func newTestFileGeocoder(t *testing.T) *FileGeocoder {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gazetteer.csv")
+	if err := os.WriteFile(path, []byte(gazetteer), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewFileGeocoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestFileGeocoder(t *testing.T) {
+	g := newTestFileGeocoder(t)
+
+	tests := []struct {
+		address string
+		want    *Result
+	}{
+		// Exact matches ignore case and punctuation
+		{"1 Market St, Springfield", &Result{Lat: 39.80, Lng: -89.64}},
+		{"1 MARKET ST springfield", &Result{Lat: 39.80, Lng: -89.64}},
+		// The longest entry contained in the address wins over shorter ones
+		{"12 Market St, Springfield", &Result{Lat: 39.79, Lng: -89.66}},
+		{"99 Elm Ave, Springfield", &Result{Lat: 39.78, Lng: -89.65}},
+		// Only whole words match
+		{"5 Romeo Street, Verona", nil},
+		{"Via del Corso 1, Rome", &Result{Lat: 41.90, Lng: 12.50}},
+		{"", nil},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		got, err := g.Geocode(context.Background(), tt.address)
+		if tt.want == nil {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Geocode(%q) = %v, %v, want ErrNotFound", tt.address, got, err)
+			}
+			continue
+		}
+		if err != nil || *got != *tt.want {
+			t.Errorf("Geocode(%q) = %v, %v, want %v", tt.address, got, err, *tt.want)
+		}
+	}
+}
+
+func TestNewFileGeocoderRejectsInvalidCoordinates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gazetteer.csv")
+	if err := os.WriteFile(path, []byte("address,lat,lng\nRome,41.90,east\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileGeocoder(path); err == nil {
+		t.Fatal("loaded a gazetteer with invalid coordinates")
+	}
+}
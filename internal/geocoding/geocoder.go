@@ -0,0 +1,63 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrNotFound means the address is unknown to the geocoder; retrying will not help.
+var ErrNotFound = errors.New("address not found")
+
+// Result is the position of a geocoded address.
+type Result struct {
+	Lat float64
+	Lng float64
+}
+
+// Geocoder turns a free text address into coordinates.
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (*Result, error)
+}
+
+// NewFromEnv builds the geocoder selected by the GEOCODER environment variable.
+// It returns nil when geocoding is disabled.
+//
+//	GEOCODER=       geocoding is disabled (default)
+//	GEOCODER=file   looks addresses up in the CSV gazetteer at GEOCODER_FILE (default "gazetteer.csv"), for development and tests
+//	GEOCODER=http   queries the Nominatim compatible search API at GEOCODER_URL, sending GEOCODER_USER_AGENT
+func NewFromEnv() (Geocoder, error) {
+	switch os.Getenv("GEOCODER") {
+	case "":
+		return nil, nil
+	case "file":
+		path := os.Getenv("GEOCODER_FILE")
+		if path == "" {
+			path = "gazetteer.csv"
+		}
+		return NewFileGeocoder(path)
+	case "http":
+		baseURL := os.Getenv("GEOCODER_URL")
+		if baseURL == "" {
+			return nil, fmt.Errorf("GEOCODER_URL is not set in the environment variables")
+		}
+		userAgent := os.Getenv("GEOCODER_USER_AGENT")
+		if userAgent == "" {
+			userAgent = "crud-go-gin"
+		}
+		return NewHTTPGeocoder(baseURL, userAgent), nil
+	default:
+		return nil, fmt.Errorf("unknown GEOCODER %q", os.Getenv("GEOCODER"))
+	}
+}
+
+// normalizeAddress lower cases the address and reduces it to words separated by single spaces.
+func normalizeAddress(address string) string {
+	words := strings.FieldsFunc(strings.ToLower(address), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
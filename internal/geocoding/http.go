@@ -0,0 +1,67 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// HTTPGeocoder queries a Nominatim compatible search API,
+// e.g. https://nominatim.openstreetmap.org/search.
+type HTTPGeocoder struct {
+	BaseURL   string
+	UserAgent string
+	client    *http.Client
+}
+
+func NewHTTPGeocoder(baseURL string, userAgent string) *HTTPGeocoder {
+	return &HTTPGeocoder{
+		BaseURL:   baseURL,
+		UserAgent: userAgent,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (g *HTTPGeocoder) Geocode(ctx context.Context, address string) (*Result, error) {
+	params := url.Values{"q": {address}, "format": {"json"}, "limit": {"1"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.BaseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", g.UserAgent)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("geocoder returned status %d", resp.StatusCode)
+	}
+
+	var places []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, ErrNotFound
+	}
+
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return nil, err
+	}
+	lng, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Lat: lat, Lng: lng}, nil
+}
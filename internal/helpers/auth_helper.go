@@ -2,9 +2,12 @@ package helpers
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 
-	"os"
+	"strings"
 	"time"
 
 	"github.com/alpha-154/crud-go-gin/internal/config"
@@ -43,68 +46,163 @@ func HashPassword(password string) (string, error) {
 	return string(hashedPassword), nil
 }
 
-// GenerateRefreshToken generates a new JWT refresh token for the user.
-func GenerateRefreshToken(userID string) (string, error) {
-	refreshToken := jwt.New(jwt.SigningMethodHS256)
-	rtClaims := refreshToken.Claims.(jwt.MapClaims)
-	rtClaims["user_id"] = userID
-	rtClaims["exp"] = time.Now().Add(time.Hour * 24 * 7).Unix() // Set expiration to 7 days
+const (
+	AccessTokenTTL        = time.Hour
+	RefreshTokenTTL       = time.Hour * 24 * 7
+	ImpersonationTokenTTL = time.Minute * 15
+)
 
-	// Sign the token with the secret key from environment variables
-	refreshTokenString, err := refreshToken.SignedString([]byte(os.Getenv("JWT_SECRET")))
-	if err != nil {
+// GenerateRandomID returns a hex encoded random identifier suitable for token IDs.
+func GenerateRandomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
-	return refreshTokenString, nil
+	return hex.EncodeToString(b), nil
 }
 
-// Generate AccessToken & RefreshToken for the user
-func GenerateTokenPair(userID string, role string) (*dto.TokenPair, error) {
+// Generate AccessToken & RefreshToken for the user.
+// The refresh token joins the given family, or starts a new one when familyID is empty.
+func GenerateTokenPair(userID string, role string, familyID string) (*dto.TokenPair, error) {
+	if familyID == "" {
+		id, err := GenerateRandomID()
+		if err != nil {
+			return nil, err
+		}
+		familyID = id
+	}
+	jti, err := GenerateRandomID()
+	if err != nil {
+		return nil, err
+	}
+	accessJTI, err := GenerateRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	accessExpiresAt := now.Add(AccessTokenTTL)
+	refreshExpiresAt := now.Add(RefreshTokenTTL)
+
 	// Generate access token
-	accessToken := jwt.New(jwt.SigningMethodHS256)
-	accessClaims := accessToken.Claims.(jwt.MapClaims)
-	accessClaims["user_id"] = userID
-	accessClaims["role"] = role
-	accessClaims["exp"] = time.Now().Add(time.Hour).Unix()
-	accessClaims["type"] = "access"
+	accessClaims := jwt.MapClaims{
+		"user_id": userID,
+		"role":    role,
+		"sid":     familyID,
+		"jti":     accessJTI,
+		"exp":     accessExpiresAt.Unix(),
+		"type":    "access",
+	}
 
 	// Generate refresh token
-	refreshToken := jwt.New(jwt.SigningMethodHS256)
-	refreshClaims := refreshToken.Claims.(jwt.MapClaims)
-	refreshClaims["user_id"] = userID
-	refreshClaims["exp"] = time.Now().Add(time.Hour * 24 * 7).Unix() // Set expiration to 7 days
-
-	refreshClaims["type"] = "refresh"
+	refreshClaims := jwt.MapClaims{
+		"user_id":   userID,
+		"exp":       refreshExpiresAt.Unix(), // Set expiration to 7 days
+		"jti":       jti,
+		"family_id": familyID,
+		"type":      "refresh",
+	}
 
-	// Sign tokens
-	accessTokenString, err := accessToken.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	// Sign tokens with the active key
+	accessTokenString, err := GetKeyManager().Sign(accessClaims)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshTokenString, err := refreshToken.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	refreshTokenString, err := GetKeyManager().Sign(refreshClaims)
 	if err != nil {
 		return nil, err
 	}
 
 	return &dto.TokenPair{
-		AccessToken:  accessTokenString,
-		RefreshToken: refreshTokenString,
+		AccessToken:      accessTokenString,
+		RefreshToken:     refreshTokenString,
+		AccessJTI:        accessJTI,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshJTI:       jti,
+		FamilyID:         familyID,
+		RefreshExpiresAt: refreshExpiresAt,
 	}, nil
 }
 
+// GenerateClientAccessToken signs an access token for a third-party client acting for the user.
+// It carries the granted scopes and no session, and is never paired with a refresh token.
+func GenerateClientAccessToken(userID string, role string, clientID string, scopes []string) (string, string, time.Time, error) {
+	jti, err := GenerateRandomID()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(AccessTokenTTL)
+
+	token, err := GetKeyManager().Sign(jwt.MapClaims{
+		"user_id":   userID,
+		"role":      role,
+		"client_id": clientID,
+		"scope":     strings.Join(scopes, " "),
+		"jti":       jti,
+		"exp":       expiresAt.Unix(),
+		"type":      "access",
+	})
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return token, jti, expiresAt, nil
+}
+
+// GenerateImpersonationToken signs a short-lived access token for the user that names the
+// admin acting as them in an "act" claim (RFC 8693). It has no session and no refresh token.
+func GenerateImpersonationToken(userID string, role string, actorID string) (string, string, time.Time, error) {
+	jti, err := GenerateRandomID()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(ImpersonationTokenTTL)
+
+	token, err := GetKeyManager().Sign(jwt.MapClaims{
+		"user_id": userID,
+		"role":    role,
+		"act":     map[string]string{"sub": actorID},
+		"jti":     jti,
+		"exp":     expiresAt.Unix(),
+		"type":    "access",
+	})
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return token, jti, expiresAt, nil
+}
+
 // ValidateRefreshToken validates the provided refresh token and returns the claims if valid.
 func ValidateRefreshToken(tokenString string) (map[string]interface{}, error) {
+	claims, err := GetKeyManager().Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims["type"] != "refresh" {
+		return nil, errors.New("invalid token type")
+	}
+	return claims, nil
+}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+// ValidateAccessToken validates the provided access token and returns the claims if valid.
+func ValidateAccessToken(tokenString string) (map[string]interface{}, error) {
+	claims, err := GetKeyManager().Parse(tokenString)
 	if err != nil {
 		return nil, err
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return nil, errors.New("invalid token")
+	if claims["type"] != "access" {
+		return nil, errors.New("invalid token type")
 	}
 	return claims, nil
 }
+
+// ExtractBearerToken returns the token of a "Bearer <token>" Authorization header.
+func ExtractBearerToken(authHeader string) string {
+	return strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+}
+
+// HashToken returns the hex encoded SHA-256 hash of a token, used to store tokens we hand out.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
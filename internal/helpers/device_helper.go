@@ -0,0 +1,43 @@
+package helpers
+
+import "strings"
+
+// DescribeDevice derives a short human readable device name from a User-Agent header.
+func DescribeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	platform := "Unknown device"
+	switch {
+	case strings.Contains(ua, "iphone"):
+		platform = "iPhone"
+	case strings.Contains(ua, "ipad"):
+		platform = "iPad"
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os"):
+		platform = "Mac"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	browser := ""
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+
+	if browser == "" {
+		return platform
+	}
+	return browser + " on " + platform
+}
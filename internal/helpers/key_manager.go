@@ -0,0 +1,351 @@
+package helpers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms accepted when verifying tokens. Anything else, including HS256
+// and "none", is rejected before a key is looked up.
+var allowedSigningAlgorithms = []string{"RS256", "EdDSA"}
+
+// SigningKey is a private key used to sign tokens, identified by its kid.
+type SigningKey struct {
+	KID       string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	if k.Algorithm == "RS256" {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// KeyManager holds the signing keys loaded from JWT_KEYS_DIR.
+// The newest key signs new tokens; older keys are kept for verification
+// until every token they signed has expired.
+type KeyManager struct {
+	mu        sync.RWMutex
+	dir       string
+	algorithm string
+	rotation  time.Duration
+	keys      map[string]*SigningKey
+	active    *SigningKey
+}
+
+var (
+	keyManager     *KeyManager
+	keyManagerOnce sync.Once
+)
+
+// GetKeyManager returns the process wide key manager, configuring it from the environment on first use:
+//
+//	JWT_KEYS_DIR              directory of PEM encoded private keys, one key per <kid>.pem file
+//	JWT_EPHEMERAL_KEYS        "true" to sign with an in-memory key when JWT_KEYS_DIR is not set, for development and tests
+//	JWT_SIGNING_ALG           algorithm of generated keys, "EdDSA" (default) or "RS256"
+//	JWT_KEY_ROTATION_INTERVAL how often a new signing key is generated, e.g. "720h" (disabled when empty)
+func GetKeyManager() *KeyManager {
+	keyManagerOnce.Do(func() {
+		km := &KeyManager{
+			dir:       os.Getenv("JWT_KEYS_DIR"),
+			algorithm: os.Getenv("JWT_SIGNING_ALG"),
+			keys:      make(map[string]*SigningKey),
+		}
+		if km.algorithm == "" {
+			km.algorithm = "EdDSA"
+		}
+		if interval := os.Getenv("JWT_KEY_ROTATION_INTERVAL"); interval != "" {
+			d, err := time.ParseDuration(interval)
+			if err != nil {
+				log.Fatal("Invalid JWT_KEY_ROTATION_INTERVAL:", err)
+			}
+			km.rotation = d
+		}
+		if err := km.init(); err != nil {
+			log.Fatal("Failed to load JWT signing keys:", err)
+		}
+		keyManager = km
+	})
+	return keyManager
+}
+
+func (km *KeyManager) init() error {
+	if km.algorithm != "EdDSA" && km.algorithm != "RS256" {
+		return fmt.Errorf("unsupported JWT_SIGNING_ALG %q", km.algorithm)
+	}
+
+	if km.dir == "" {
+		// Tokens signed with an ephemeral key do not survive a restart and are rejected by
+		// other instances, so this is only allowed when asked for, e.g. in development
+		if os.Getenv("JWT_EPHEMERAL_KEYS") != "true" {
+			return errors.New("JWT_KEYS_DIR is not set; set JWT_EPHEMERAL_KEYS=true to sign with a throwaway key")
+		}
+		log.Println("JWT_KEYS_DIR is not set, using an ephemeral signing key")
+		key, err := generateSigningKey(km.algorithm)
+		if err != nil {
+			return err
+		}
+		km.keys[key.KID] = key
+		km.active = key
+		return nil
+	}
+
+	if err := os.MkdirAll(km.dir, 0o700); err != nil {
+		return err
+	}
+	if err := km.Reload(); err != nil {
+		return err
+	}
+	if km.active == nil {
+		return km.Rotate()
+	}
+	return nil
+}
+
+// Reload reads every key file from the keys directory and selects the newest one as active.
+func (km *KeyManager) Reload() error {
+	if km.dir == "" {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(km.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*SigningKey)
+	var active *SigningKey
+	for _, path := range paths {
+		key, err := loadSigningKey(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if km.retired(key) {
+			continue
+		}
+		keys[key.KID] = key
+		if active == nil || key.CreatedAt.After(active.CreatedAt) {
+			active = key
+		}
+	}
+
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	km.keys = keys
+	km.active = active
+	return nil
+}
+
+// retired reports whether no unexpired token can have been signed with the key.
+func (km *KeyManager) retired(key *SigningKey) bool {
+	if km.rotation == 0 {
+		return false
+	}
+	return time.Since(key.CreatedAt) > km.rotation+RefreshTokenTTL
+}
+
+// Rotate generates a new signing key, stores it in the keys directory and makes it active.
+func (km *KeyManager) Rotate() error {
+	key, err := generateSigningKey(km.algorithm)
+	if err != nil {
+		return err
+	}
+
+	if km.dir != "" {
+		der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+		if err != nil {
+			return err
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(filepath.Join(km.dir, key.KID+".pem"), data, 0o600); err != nil {
+			return err
+		}
+		log.Println("Generated new JWT signing key:", key.KID)
+		return km.Reload()
+	}
+
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	km.keys[key.KID] = key
+	km.active = key
+	return nil
+}
+
+// StartRotation rotates the active key whenever it gets older than the rotation interval.
+// It does nothing when rotation is disabled.
+func (km *KeyManager) StartRotation() {
+	if km.rotation == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			// Pick up keys added by other instances sharing the directory
+			if err := km.Reload(); err != nil {
+				log.Println("Failed to reload JWT signing keys:", err)
+				continue
+			}
+
+			km.mu.RLock()
+			due := km.active == nil || time.Since(km.active.CreatedAt) >= km.rotation
+			km.mu.RUnlock()
+			if !due {
+				continue
+			}
+			if err := km.Rotate(); err != nil {
+				log.Println("Failed to rotate JWT signing key:", err)
+			}
+		}
+	}()
+}
+
+// Sign signs the claims with the active key and stamps its kid in the header.
+func (km *KeyManager) Sign(claims jwt.MapClaims) (string, error) {
+	km.mu.RLock()
+	key := km.active
+	km.mu.RUnlock()
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.Private)
+}
+
+// Parse verifies a token signed by one of the managed keys and returns its claims.
+func (km *KeyManager) Parse(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		km.mu.RLock()
+		key, ok := km.keys[kid]
+		km.mu.RUnlock()
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, errors.New("unexpected signing algorithm")
+		}
+		return key.Private.Public(), nil
+	}, jwt.WithValidMethods(allowedSigningAlgorithms))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// JWKS returns the public keys as a JSON Web Key Set.
+func (km *KeyManager) JWKS() map[string]interface{} {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	keys := make([]map[string]string, 0, len(km.keys))
+	for _, key := range km.keys {
+		jwk := map[string]string{
+			"kid": key.KID,
+			"alg": key.Algorithm,
+			"use": "sig",
+		}
+		switch pub := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		keys = append(keys, jwk)
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+func generateSigningKey(algorithm string) (*SigningKey, error) {
+	kid, err := GenerateRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	var private crypto.Signer
+	switch algorithm {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{KID: kid, Algorithm: algorithm, Private: private, CreatedAt: time.Now()}, nil
+}
+
+func loadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{
+		KID:       strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		CreatedAt: info.ModTime(),
+	}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm = "RS256"
+		key.Private = private
+	case ed25519.PrivateKey:
+		key.Algorithm = "EdDSA"
+		key.Private = private
+	default:
+		return nil, errors.New("unsupported key type, expected RSA or Ed25519")
+	}
+	return key, nil
+}
@@ -0,0 +1,128 @@
+package helpers
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// SearchTrigrams returns the distinct three letter grams of every word in the texts, used for
+// typo-tolerant matching. Words are padded with "_" so their start and end count as well.
+func SearchTrigrams(texts ...string) []string {
+	seen := make(map[string]bool)
+	grams := []string{}
+	for _, text := range texts {
+		for _, word := range searchWords(text) {
+			padded := []rune("_" + word + "_")
+			for i := 0; i+3 <= len(padded); i++ {
+				gram := string(padded[i : i+3])
+				if !seen[gram] {
+					seen[gram] = true
+					grams = append(grams, gram)
+				}
+			}
+		}
+	}
+	return grams
+}
+
+// SearchTerms returns the words of a text search query that results should contain,
+// including the words of quoted phrases. Negated words and phrases are left out.
+func SearchTerms(query string) []string {
+	terms := []string{}
+	negated := false
+	for i, part := range strings.Split(query, `"`) {
+		// Odd parts are inside quotes
+		if i%2 == 1 {
+			if !negated {
+				terms = append(terms, searchWords(part)...)
+			}
+			continue
+		}
+
+		// A phrase is negated when a "-" directly precedes its opening quote
+		negated = strings.HasSuffix(part, "-")
+		for _, field := range strings.Fields(part) {
+			if !strings.HasPrefix(field, "-") {
+				terms = append(terms, searchWords(field)...)
+			}
+		}
+	}
+	return terms
+}
+
+// snippetLength is the longest highlighted snippet returned for a field
+const snippetLength = 80
+
+// Highlight HTML escapes the text and wraps the words that start with one of the terms in
+// <mark> tags. Long texts are cut down to a snippet around the first match. It reports
+// whether anything matched.
+func Highlight(text string, terms []string) (string, bool) {
+	runes := []rune(text)
+	var b strings.Builder
+	first := -1
+
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			b.WriteString(html.EscapeString(string(runes[i])))
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if matchesTerm(strings.ToLower(word), terms) {
+			if first < 0 {
+				first = i
+			}
+			b.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+		i = j
+	}
+
+	if first < 0 {
+		return "", false
+	}
+	if len(runes) <= snippetLength {
+		return b.String(), true
+	}
+
+	// Highlight the window around the first match only
+	start := max(first-snippetLength/4, 0)
+	end := min(start+snippetLength, len(runes))
+	snippet, _ := Highlight(string(runes[start:end]), terms)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet, true
+}
+
+// matchesTerm reports whether the word starts with a term, ignoring a plural "s"
+// since the text index stems words.
+func matchesTerm(word string, terms []string) bool {
+	for _, term := range terms {
+		stem := term
+		if len(stem) > 3 {
+			stem = strings.TrimSuffix(stem, "s")
+		}
+		if strings.HasPrefix(word, stem) {
+			return true
+		}
+	}
+	return false
+}
+
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isWordRune(r) })
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
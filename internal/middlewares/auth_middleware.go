@@ -1,12 +1,15 @@
 package middlewares
 
 import (
+	"log"
 	"net/http"
-	"os"
 	"strings"
 
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+	"github.com/alpha-154/crud-go-gin/internal/services"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -18,37 +21,199 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+		// Machine clients authenticate with a personal API key
+		if strings.HasPrefix(authHeader, "ApiKey ") {
+			authenticateAPIKey(c, strings.TrimSpace(strings.TrimPrefix(authHeader, "ApiKey ")))
+			return
+		}
 
-		if err != nil || !token.Valid {
+		tokenString := helpers.ExtractBearerToken(authHeader)
+		claims, err := helpers.ValidateAccessToken(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			c.Abort()
 			return
 		}
 
-		claims := token.Claims.(jwt.MapClaims)
+		// Reject tokens revoked by logout, password change or admin actions
+		jti, _ := claims["jti"].(string)
+		if jti == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			c.Abort()
+			return
+		}
+		revoked, err := services.IsAccessTokenRevoked(jti)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+			c.Abort()
+			return
+		}
 
-		// Check token type
-		if claims["type"] != "access" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token type"})
+		// Disabled or deleted accounts lose access immediately
+		userID, _ := claims["user_id"].(string)
+		disabled, err := services.IsUserDisabled(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify user"})
+			c.Abort()
+			return
+		}
+		if disabled {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "account disabled"})
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", claims["user_id"])
+		c.Set("user_id", userID)
 		c.Set("role", claims["role"])
+		c.Set("session_id", claims["sid"])
+		c.Set("jti", jti)
+
+		// Tokens issued to third-party apps are limited to the granted scopes
+		if scope, ok := claims["scope"].(string); ok {
+			c.Set("client_id", claims["client_id"])
+			c.Set("scopes", strings.Fields(scope))
+		}
+
+		// An admin is acting as the user: keep both identities and log every request
+		if act, ok := claims["act"].(map[string]interface{}); ok {
+			actorID, _ := act["sub"].(string)
+			if actorID == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+				c.Abort()
+				return
+			}
+			c.Set("impersonator_id", actorID)
+			c.Next()
+
+			if err := services.RecordImpersonatedRequest(actorID, userID, c.Request.Method, c.Request.URL.Path, c.Writer.Status()); err != nil {
+				log.Println("Failed to record impersonated request:", err)
+			}
+			return
+		}
 		c.Next()
 	}
 }
 
-func AdminOnly() gin.HandlerFunc {
+// authenticateAPIKey sets the same context values as a Bearer token, plus the key's scopes.
+func authenticateAPIKey(c *gin.Context, key string) {
+	apiKey, user, err := services.AuthenticateAPIKey(key)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", user.UserID)
+	c.Set("role", user.Role)
+	c.Set("api_key_id", apiKey.KeyID)
+	c.Set("scopes", apiKey.Scopes)
+	c.Next()
+}
+
+// RequirePermission allows the request only if the user's current role grants every permission.
+// Permissions are looked up on each request, so role changes apply immediately.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+		granted, err := services.GetUserPermissions(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+			c.Abort()
+			return
+		}
+
+		// Requests made with an API key or an app token are also limited to their scopes
+		if scopes, ok := c.Get("scopes"); ok {
+			granted = restrictToScopes(granted, scopes.([]string))
+		}
+
+		for _, permission := range permissions {
+			if !models.HasPermission(granted, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "missing permission: " + permission})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("permissions", granted)
+		c.Next()
+	}
+}
+
+// restrictToScopes returns the permissions granted by both the role and the scopes.
+func restrictToScopes(granted []string, scopes []string) []string {
+	restricted := []string{}
+	for _, scope := range scopes {
+		if models.HasPermission(granted, scope) {
+			restricted = append(restricted, scope)
+		}
+	}
+	return restricted
+}
+
+// RequireSelfOrPermission lets users access their own record, identified by the path parameter,
+// and everyone else only with the given permission.
+func RequireSelfOrPermission(param string, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param(param) == c.GetString("user_id") {
+			c.Next()
+			return
+		}
+		RequirePermission(permission)(c)
+	}
+}
+
+// RejectImpersonation blocks impersonation tokens from user and role administration, so acting
+// as a user never lends their admin rights to the impersonator.
+func RejectImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, impersonating := c.Get("impersonator_id"); impersonating {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this action is not allowed while impersonating"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUserSession rejects API keys, third-party app tokens and impersonation tokens, so they
+// can't be used to manage the account or mint credentials beyond their own scopes.
+func RequireUserSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, impersonating := c.Get("impersonator_id"); impersonating {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this action is not allowed while impersonating"})
+			c.Abort()
+			return
+		}
+		if _, delegated := c.Get("scopes"); delegated {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this action requires signing in"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail blocks users with an unverified email when REQUIRE_EMAIL_VERIFICATION is enabled
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if config.EmailVerificationMode() == config.EmailVerificationOptional {
+			c.Next()
+			return
+		}
+
+		user, err := services.GetUserByID(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			c.Abort()
+			return
+		}
+		if !user.EmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "email verification required"})
 			c.Abort()
 			return
 		}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditLog records a sensitive operation and who performed it.
+type AuditLog struct {
+	ID         primitive.ObjectID     `bson:"_id,omitempty" json:"id,omitempty"`
+	Action     string                 `bson:"action" json:"action"`
+	ActorID    string                 `bson:"actor_id" json:"actor_id"`
+	TargetType string                 `bson:"target_type" json:"target_type"`
+	TargetID   string                 `bson:"target_id" json:"target_id"`
+	Details    map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt  time.Time              `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExternalIdentity links an account at a social login provider to a user.
+type ExternalIdentity struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Provider    string             `bson:"provider" json:"provider"`
+	Subject     string             `bson:"subject" json:"subject"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	Email       string             `bson:"email" json:"email"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	LastLoginAt time.Time          `bson:"last_login_at" json:"last_login_at"`
+}
+
+// OAuthState is a pending social login, kept until the provider redirects back.
+type OAuthState struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	StateHash    string             `bson:"state_hash" json:"-"`
+	Provider     string             `bson:"provider" json:"provider"`
+	CodeVerifier string             `bson:"code_verifier" json:"-"`
+	ExpiresAt    time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// GeoPoint is a GeoJSON point. Coordinates are [longitude, latitude].
+type GeoPoint struct {
+	Type        string    `bson:"type" json:"type"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates"`
+}
+
+// NewGeoPoint returns the GeoJSON point for the coordinates.
+func NewGeoPoint(lng float64, lat float64) *GeoPoint {
+	return &GeoPoint{Type: "Point", Coordinates: []float64{lng, lat}}
+}
+
+// Valid reports whether the point is a GeoJSON point with coordinates on the globe.
+func (p *GeoPoint) Valid() bool {
+	return p.Type == "Point" && len(p.Coordinates) == 2 &&
+		p.Coordinates[0] >= -180 && p.Coordinates[0] <= 180 &&
+		p.Coordinates[1] >= -90 && p.Coordinates[1] <= 90
+}
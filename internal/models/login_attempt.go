@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt counts recent failed sign-ins for an account ("email:<address>") or a client ("ip:<address>").
+type LoginAttempt struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Key           string             `bson:"key" json:"key"`
+	Failures      int                `bson:"failures" json:"failures"`
+	LockedUntil   time.Time          `bson:"locked_until" json:"locked_until"`
+	LastFailureAt time.Time          `bson:"last_failure_at" json:"last_failure_at"`
+	ExpiresAt     time.Time          `bson:"expires_at" json:"expires_at"`
+}
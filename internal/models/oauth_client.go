@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OAuthScopes are the scopes third-party apps can request. Each maps to the restaurant
+// permission of the same name and is further limited by the user's role.
+var OAuthScopes = []string{
+	PermissionRestaurantsRead,
+	PermissionRestaurantsWrite,
+	PermissionRestaurantsAdmin,
+}
+
+// OAuthClient is a third-party app registered by a user. Confidential clients authenticate
+// with a secret, which is only stored hashed; public clients rely on PKCE alone.
+type OAuthClient struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ClientID     string             `bson:"client_id" json:"client_id"`
+	Name         string             `bson:"name" json:"name"`
+	OwnerID      string             `bson:"owner_id" json:"owner_id"`
+	SecretHash   string             `bson:"secret_hash,omitempty" json:"-"`
+	Confidential bool               `bson:"confidential" json:"confidential"`
+	RedirectURIs []string           `bson:"redirect_uris" json:"redirect_uris"`
+	Scopes       []string           `bson:"scopes" json:"scopes"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// OAuthCode is a pending authorization code. Only its SHA-256 hash is stored.
+type OAuthCode struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	CodeHash      string             `bson:"code_hash" json:"-"`
+	ClientID      string             `bson:"client_id" json:"client_id"`
+	UserID        string             `bson:"user_id" json:"user_id"`
+	RedirectURI   string             `bson:"redirect_uri" json:"redirect_uri"`
+	Scopes        []string           `bson:"scopes" json:"scopes"`
+	CodeChallenge string             `bson:"code_challenge" json:"-"`
+	ExpiresAt     time.Time          `bson:"expires_at" json:"expires_at"`
+}
+
+// OAuthConsent records the scopes a user granted to a client.
+type OAuthConsent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	ClientID  string             `bson:"client_id" json:"client_id"`
+	Scopes    []string           `bson:"scopes" json:"scopes"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// OAuthToken tracks an access token issued to a client so it can be introspected
+// and revoked with its consent or client.
+type OAuthToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	JTI       string             `bson:"jti" json:"jti"`
+	ClientID  string             `bson:"client_id" json:"client_id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Scopes    []string           `bson:"scopes" json:"scopes"`
+	GrantType string             `bson:"grant_type" json:"grant_type"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
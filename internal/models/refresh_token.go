@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is the server-side record of an issued refresh token.
+// Every token belongs to a family that starts at sign-in and is rotated on each refresh.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	JTI       string             `bson:"jti" json:"jti"`
+	FamilyID  string             `bson:"family_id" json:"family_id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Used      bool               `bson:"used" json:"used"`
+	Revoked   bool               `bson:"revoked" json:"revoked"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
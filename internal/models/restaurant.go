@@ -1,12 +1,39 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Geocoding states of a restaurant address
+const (
+	GeocodePending  = "pending"   // waiting for the geocoder, possibly retrying
+	GeocodeDone     = "done"      // location was filled in from the address
+	GeocodeNotFound = "not_found" // the geocoder does not know the address
+	GeocodeFailed   = "failed"    // gave up after repeated errors
+	GeocodeManual   = "manual"    // location was sent by the client
+)
 
 type Restaurant struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	RestaurantID string             `bson:"restaurant_id" json:"restaurant_id"` // Store ObjectID as a string
-	Name         string             `json:"name"`
-	Address      string             `json:"address"`
-	Email        string             `json:"email"`
-	Cuisine      string             `json:"cuisine"`
+	Name         string             `json:"name" binding:"required,max=200"`
+	Address      string             `json:"address" binding:"required,address"`
+	Email        string             `json:"email" binding:"required,email"`
+	Phone        string             `bson:"phone,omitempty" json:"phone,omitempty" binding:"omitempty,e164"`
+	Cuisine      string             `json:"cuisine" binding:"required,cuisine"`
+	Location     *GeoPoint          `bson:"location,omitempty" json:"location,omitempty" binding:"omitempty,geopoint"`
+
+	// Progress of filling in Location from Address, see StartGeocoder
+	GeocodeStatus string     `bson:"geocode_status,omitempty" json:"geocode_status,omitempty"`
+	GeocodeError  string     `bson:"geocode_error,omitempty" json:"geocode_error,omitempty"`
+	GeocodedAt    *time.Time `bson:"geocoded_at,omitempty" json:"geocoded_at,omitempty"`
+
+	// Only the owner, managers and admins may modify the restaurant
+	OwnerID    string   `bson:"owner_id" json:"owner_id"`
+	ManagerIDs []string `bson:"manager_ids" json:"manager_ids"`
+
+	// Trigrams of name, cuisine and address for typo-tolerant search
+	SearchGrams []string `bson:"search_grams,omitempty" json:"-"`
 }
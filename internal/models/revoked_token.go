@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RevokedToken denylists an access token by its JTI until the token would have expired.
+type RevokedToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	JTI       string             `bson:"jti" json:"jti"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Reason    string             `bson:"reason" json:"reason"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
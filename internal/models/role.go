@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Permissions granted through roles
+const (
+	PermissionAll              = "*"
+	PermissionRestaurantsRead  = "restaurants:read"
+	PermissionRestaurantsWrite = "restaurants:write"
+	PermissionRestaurantsAdmin = "restaurants:admin" // modify restaurants owned by anyone
+	PermissionUsersRead        = "users:read"
+	PermissionUsersWrite       = "users:write"
+	PermissionUsersImpersonate = "users:impersonate" // act as another user for support
+	PermissionRolesManage      = "roles:manage"
+)
+
+// KnownPermissions lists every permission a role may grant.
+var KnownPermissions = []string{
+	PermissionAll,
+	PermissionRestaurantsRead,
+	PermissionRestaurantsWrite,
+	PermissionRestaurantsAdmin,
+	PermissionUsersRead,
+	PermissionUsersWrite,
+	PermissionUsersImpersonate,
+	PermissionRolesManage,
+}
+
+// Role is a named set of permissions assigned to users through User.Role.
+type Role struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	Permissions []string           `bson:"permissions" json:"permissions"`
+	BuiltIn     bool               `bson:"built_in" json:"built_in"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// HasPermission reports whether the permission set grants the permission.
+func HasPermission(granted []string, permission string) bool {
+	for _, p := range granted {
+		if p == PermissionAll || p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermissions reports whether the permission set grants every one of the permissions.
+func HasPermissions(granted []string, permissions []string) bool {
+	for _, p := range permissions {
+		if !HasPermission(granted, p) {
+			return false
+		}
+	}
+	return true
+}
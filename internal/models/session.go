@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session represents a signed-in device. Its SessionID is also the family ID
+// of the refresh tokens issued to that device.
+type Session struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SessionID string             `bson:"session_id" json:"session_id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Device    string             `bson:"device" json:"device"`
+	UserAgent string             `bson:"user_agent" json:"user_agent"`
+	IP        string             `bson:"ip" json:"ip"`
+
+	// Most recent access token issued for the session
+	AccessJTI       string    `bson:"access_jti" json:"-"`
+	AccessExpiresAt time.Time `bson:"access_expires_at" json:"-"`
+
+	Revoked    bool       `bson:"revoked" json:"revoked"`
+	RevokedAt  *time.Time `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	LastUsedAt time.Time  `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt  time.Time  `bson:"expires_at" json:"expires_at"`
+}
@@ -6,14 +6,41 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// User is the stored user document. API responses use dto.UserView instead,
+// so secrets are never serialized even if a handler forgets to convert.
 type User struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID       string             `bson:"user_id" json:"user_id"`
-	Name         string             `bson:"name" json:"name" binding:"required"`
-	Email        string             `bson:"email" json:"email" binding:"required,email"`
-	Password     string             `bson:"password" json:"password" binding:"required,min=6"`
-	Role         string             `bson:"role" json:"role"`
-	RefreshToken string             `bson:"refresh_token" json:"refresh_token,omitempty"`
-	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID   string             `bson:"user_id" json:"user_id"`
+	Name     string             `bson:"name" json:"name"`
+	Email    string             `bson:"email" json:"email"`
+	Password string             `bson:"password" json:"-"`
+	Role     string             `bson:"role" json:"role"`
+
+	// Previous password hashes, newest first, see services.setUserPassword
+	PasswordHistory []string `bson:"password_history,omitempty" json:"-"`
+
+	EmailVerified   bool       `bson:"email_verified" json:"email_verified"`
+	EmailVerifiedAt *time.Time `bson:"email_verified_at,omitempty" json:"email_verified_at,omitempty"`
+
+	// New address waiting for confirmation, see services.RequestEmailChange
+	PendingEmail string `bson:"pending_email,omitempty" json:"pending_email,omitempty"`
+
+	// Disabled users cannot sign in or use existing tokens
+	Disabled   bool       `bson:"disabled" json:"disabled"`
+	DisabledAt *time.Time `bson:"disabled_at,omitempty" json:"disabled_at,omitempty"`
+
+	// TOTP two-factor authentication, recovery codes are stored hashed
+	MFAEnabled       bool     `bson:"mfa_enabled" json:"mfa_enabled"`
+	MFASecret        string   `bson:"mfa_secret,omitempty" json:"-"`
+	MFAPendingSecret string   `bson:"mfa_pending_secret,omitempty" json:"-"`
+	MFARecoveryCodes []string `bson:"mfa_recovery_codes,omitempty" json:"-"`
+	MFALastStep      int64    `bson:"mfa_last_step,omitempty" json:"-"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
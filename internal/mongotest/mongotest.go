@@ -0,0 +1,99 @@
+// Package mongotest runs tests against the MongoDB server at MONGODB_URI, in a database of
+// their own that is dropped afterwards. Tests that need the database are skipped when
+// MONGODB_URI is not set, e.g. with a local server:
+//
+//	MONGODB_URI=mongodb://localhost:27017 go test ./...
+package mongotest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Database is the throwaway database of a test binary.
+// A nil Database means no server is configured.
+type Database struct {
+	db *mongo.Database
+}
+
+// Setup connects config.DB to MONGODB_URI and points DB_NAME at a new database.
+// The services keep their collection handles, so call it once, e.g. from TestMain,
+// and Reset the data between tests. It returns nil when MONGODB_URI is not set.
+func Setup() *Database {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		return nil
+	}
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx, nil); err != nil {
+		panic(fmt.Sprintf("mongotest: MONGODB_URI is set but the server does not answer: %v", err))
+	}
+
+	name := fmt.Sprintf("crud_go_gin_test_%d", time.Now().UnixNano())
+	os.Setenv("DB_NAME", name)
+	config.DB = client
+	return &Database{db: client.Database(name)}
+}
+
+// Reset empties every collection, keeping their indexes, or skips the test when
+// there is no server.
+func (d *Database) Reset(t testing.TB) {
+	t.Helper()
+	if d == nil {
+		t.Skip("MONGODB_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	names, err := d.db.ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if _, err := d.db.Collection(name).DeleteMany(ctx, bson.D{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Documents returns the documents of a collection.
+func (d *Database) Documents(t testing.TB, collection string) []bson.D {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := d.db.Collection(collection).Find(ctx, bson.D{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []bson.D
+	if err := cursor.All(ctx, &docs); err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
+
+// Drop removes the database, for TestMain to call once the tests ran.
+func (d *Database) Drop() {
+	if d == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	d.db.Drop(ctx)
+}
@@ -0,0 +1,346 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider types supported in the providers file
+const (
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+)
+
+// ProviderConfig is one entry of the providers file. Endpoints of OIDC providers
+// are discovered from the issuer unless they are set explicitly.
+type ProviderConfig struct {
+	Type            string   `json:"type"`
+	Issuer          string   `json:"issuer"`
+	AuthURL         string   `json:"auth_url"`
+	TokenURL        string   `json:"token_url"`
+	UserInfoURL     string   `json:"userinfo_url"`
+	ClientID        string   `json:"client_id"`
+	ClientSecret    string   `json:"client_secret"`
+	ClientSecretEnv string   `json:"client_secret_env"`
+	RedirectURL     string   `json:"redirect_url"`
+	Scopes          []string `json:"scopes"`
+}
+
+// Identity is the external account returned by a provider.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// discoveryRetryDelay is how long a failed discovery is answered from memory before the issuer is asked again.
+const discoveryRetryDelay = 10 * time.Second
+
+// Provider runs the authorization code flow against one external identity provider.
+type Provider struct {
+	Name   string
+	config ProviderConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	discovered  *endpoints // nil until discovery succeeds
+	discoverErr error
+	retryAt     time.Time
+}
+
+// endpoints are the URLs of a provider, configured or discovered from its issuer.
+type endpoints struct {
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+}
+
+// LoadProvidersFromEnv reads the providers file named by OAUTH_PROVIDERS_FILE
+// (default "oauth_providers.json"). A missing file means no providers are configured.
+func LoadProvidersFromEnv() (map[string]*Provider, error) {
+	path := os.Getenv("OAUTH_PROVIDERS_FILE")
+	if path == "" {
+		path = "oauth_providers.json"
+	}
+	providers, err := LoadProviders(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]*Provider{}, nil
+	}
+	return providers, err
+}
+
+// LoadProviders reads a JSON file mapping provider names to their configuration, e.g.
+//
+//	{"google": {"type": "oidc", "issuer": "https://accounts.google.com", "client_id": "...",
+//	            "client_secret_env": "GOOGLE_CLIENT_SECRET", "redirect_url": "...", "scopes": ["openid", "email", "profile"]}}
+func LoadProviders(path string) (map[string]*Provider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs map[string]ProviderConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	providers := make(map[string]*Provider, len(configs))
+	for name, cfg := range configs {
+		if cfg.ClientSecretEnv != "" {
+			cfg.ClientSecret = os.Getenv(cfg.ClientSecretEnv)
+		}
+		if cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return nil, fmt.Errorf("provider %q: client_id and redirect_url are required", name)
+		}
+
+		switch cfg.Type {
+		case TypeOIDC:
+			if cfg.Issuer == "" && (cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "") {
+				return nil, fmt.Errorf("provider %q: issuer or explicit endpoints are required", name)
+			}
+			if len(cfg.Scopes) == 0 {
+				cfg.Scopes = []string{"openid", "email", "profile"}
+			}
+		case TypeGitHub:
+			if cfg.AuthURL == "" {
+				cfg.AuthURL = "https://github.com/login/oauth/authorize"
+			}
+			if cfg.TokenURL == "" {
+				cfg.TokenURL = "https://github.com/login/oauth/access_token"
+			}
+			if cfg.UserInfoURL == "" {
+				cfg.UserInfoURL = "https://api.github.com/user"
+			}
+			if len(cfg.Scopes) == 0 {
+				cfg.Scopes = []string{"read:user", "user:email"}
+			}
+		default:
+			return nil, fmt.Errorf("provider %q: unknown type %q", name, cfg.Type)
+		}
+
+		providers[name] = &Provider{
+			Name:   name,
+			config: cfg,
+			client: &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+	return providers, nil
+}
+
+// endpoints returns the configured endpoints, with missing OIDC ones filled in from the
+// issuer's discovery document. A successful discovery is kept; after a failure the issuer
+// is asked again once discoveryRetryDelay has passed.
+func (p *Provider) endpoints(ctx context.Context) (endpoints, error) {
+	configured := endpoints{AuthURL: p.config.AuthURL, TokenURL: p.config.TokenURL, UserInfoURL: p.config.UserInfoURL}
+	if p.config.Type != TypeOIDC || (configured.AuthURL != "" && configured.TokenURL != "" && configured.UserInfoURL != "") {
+		return configured, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered != nil {
+		return *p.discovered, nil
+	}
+	if time.Now().Before(p.retryAt) {
+		return endpoints{}, p.discoverErr
+	}
+
+	discovered, err := p.discover(ctx, configured)
+	if err != nil {
+		p.discoverErr = err
+		p.retryAt = time.Now().Add(discoveryRetryDelay)
+		return endpoints{}, err
+	}
+	p.discovered = &discovered
+	return discovered, nil
+}
+
+// discover fills in the missing endpoints from the issuer's discovery document.
+func (p *Provider) discover(ctx context.Context, configured endpoints) (endpoints, error) {
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, "", &doc); err != nil {
+		return endpoints{}, err
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(p.config.Issuer, "/") {
+		return endpoints{}, errors.New("discovery document issuer mismatch")
+	}
+
+	discovered := configured
+	if discovered.AuthURL == "" {
+		discovered.AuthURL = doc.AuthorizationEndpoint
+	}
+	if discovered.TokenURL == "" {
+		discovered.TokenURL = doc.TokenEndpoint
+	}
+	if discovered.UserInfoURL == "" {
+		discovered.UserInfoURL = doc.UserInfoEndpoint
+	}
+	return discovered, nil
+}
+
+// AuthCodeURL returns the provider URL the user is sent to, bound to the state and PKCE challenge.
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, codeChallenge string) (string, error) {
+	e, err := p.endpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(e.AuthURL, "?") {
+		separator = "&"
+	}
+	return e.AuthURL + separator + params.Encode(), nil
+}
+
+// Exchange trades the authorization code for the provider's access token.
+func (p *Provider) Exchange(ctx context.Context, code string, codeVerifier string) (string, error) {
+	e, err := p.endpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := p.do(req, &token); err != nil {
+		return "", err
+	}
+	if token.Error != "" || token.AccessToken == "" {
+		return "", fmt.Errorf("token exchange failed: %s", token.Error)
+	}
+	return token.AccessToken, nil
+}
+
+// FetchIdentity returns the external account the access token belongs to.
+func (p *Provider) FetchIdentity(ctx context.Context, accessToken string) (*Identity, error) {
+	if p.config.Type == TypeGitHub {
+		return p.fetchGitHubIdentity(ctx, accessToken)
+	}
+	e, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Subject       string      `json:"sub"`
+		Email         string      `json:"email"`
+		EmailVerified interface{} `json:"email_verified"`
+		Name          string      `json:"name"`
+	}
+	if err := p.getJSON(ctx, e.UserInfoURL, accessToken, &info); err != nil {
+		return nil, err
+	}
+	if info.Subject == "" {
+		return nil, errors.New("provider returned no subject")
+	}
+
+	// Some providers send email_verified as a string
+	verified := info.EmailVerified == true || info.EmailVerified == "true"
+	return &Identity{Subject: info.Subject, Email: strings.ToLower(info.Email), EmailVerified: verified, Name: info.Name}, nil
+}
+
+func (p *Provider) fetchGitHubIdentity(ctx context.Context, accessToken string) (*Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.config.UserInfoURL, accessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("provider returned no user id")
+	}
+
+	// The profile email may be unverified or hidden, so use the verified primary address
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.config.UserInfoURL, "/")+"/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{Subject: fmt.Sprint(user.ID), Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = strings.ToLower(e.Email)
+			identity.EmailVerified = e.Verified
+		}
+	}
+	return identity, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return p.do(req, v)
+}
+
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: unexpected status %d", req.Method, req.URL.Host, resp.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
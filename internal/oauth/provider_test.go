@@ -0,0 +1,91 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newDiscoveryServer serves a discovery document, answering 503 to the first failures requests.
+func newDiscoveryServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"userinfo_endpoint":      server.URL + "/userinfo",
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newOIDCProvider(issuer string) *Provider {
+	return &Provider{
+		Name:   "test",
+		config: ProviderConfig{Type: TypeOIDC, Issuer: issuer, ClientID: "client", RedirectURL: "http://localhost/callback"},
+		client: &http.Client{Timeout: time.Second},
+	}
+}
+
+func TestDiscoveryIsSharedByConcurrentLogins(t *testing.T) {
+	server, requests := newDiscoveryServer(t, 0)
+	p := newOIDCProvider(server.URL)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			authURL, err := p.AuthCodeURL(context.Background(), "state", "challenge")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !strings.HasPrefix(authURL, server.URL+"/authorize?") {
+				t.Errorf("AuthCodeURL = %q", authURL)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("discovery document fetched %d times, want once", n)
+	}
+}
+
+func TestDiscoveryIsRetriedAfterFailure(t *testing.T) {
+	server, requests := newDiscoveryServer(t, 1)
+	p := newOIDCProvider(server.URL)
+
+	if _, err := p.AuthCodeURL(context.Background(), "state", "challenge"); err == nil {
+		t.Fatal("AuthCodeURL succeeded while the issuer was down")
+	}
+	// Within the retry delay the failure is answered without asking the issuer
+	if _, err := p.AuthCodeURL(context.Background(), "state", "challenge"); err == nil || requests.Load() != 1 {
+		t.Fatalf("second attempt: err = %v after %d requests, want the cached failure", err, requests.Load())
+	}
+
+	p.mu.Lock()
+	p.retryAt = time.Now()
+	p.mu.Unlock()
+	if _, err := p.AuthCodeURL(context.Background(), "state", "challenge"); err != nil {
+		t.Fatalf("AuthCodeURL after the retry delay: %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("discovery document fetched %d times, want 2", n)
+	}
+}
@@ -0,0 +1,128 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RangeSource returns the breached hashes sharing a 5 character SHA-1 prefix, mapped from
+// the remaining 35 characters to how often they were seen. Only the prefix leaves the
+// caller (k-anonymity), so an online range API can be plugged in the same way.
+type RangeSource interface {
+	Range(prefix string) (map[string]int, error)
+}
+
+// IsBreached reports whether the password appears in the breached hash list.
+func IsBreached(source RangeSource, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := source.Range(hash[:5])
+	if err != nil {
+		return false, err
+	}
+	return suffixes[hash[5:]] > 0, nil
+}
+
+// FileSource looks up ranges in a local copy of the Pwned Passwords SHA-1 list: one
+// "HASH:COUNT" line per password, upper case and sorted by hash. The file is binary
+// searched, so it is never loaded into memory.
+type FileSource struct {
+	Path string
+}
+
+// Range returns the suffixes of the hashes starting with prefix.
+func (s *FileSource) Range(prefix string) (map[string]int, error) {
+	prefix = strings.ToUpper(prefix)
+
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the smallest offset whose line is not before the prefix
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		_, line, err := lineAt(f, mid)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" || hashKey(line, len(prefix)) >= prefix {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	start, _, err := lineAt(f, lo)
+	if err != nil {
+		return nil, err
+	}
+
+	suffixes := make(map[string]int)
+	scanner := bufio.NewScanner(io.NewSectionReader(f, start, info.Size()-start))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, prefix) {
+			break
+		}
+		hash, count, _ := strings.Cut(line, ":")
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			n = 1
+		}
+		suffixes[hash[len(prefix):]] = n
+	}
+	return suffixes, scanner.Err()
+}
+
+// lineAt returns the first line starting at or after offset, with its position.
+// The line is empty at the end of the file.
+func lineAt(f *os.File, offset int64) (int64, string, error) {
+	reader := bufio.NewReader(io.NewSectionReader(f, offset, 1<<62))
+	start := offset
+	if offset > 0 {
+		// We are probably in the middle of a line, skip to the next one unless
+		// the offset is right after a newline
+		var prev [1]byte
+		if _, err := f.ReadAt(prev[:], offset-1); err != nil {
+			return 0, "", err
+		}
+		if prev[0] != '\n' {
+			skipped, err := reader.ReadString('\n')
+			if err == io.EOF {
+				return offset + int64(len(skipped)), "", nil
+			}
+			if err != nil {
+				return 0, "", err
+			}
+			start += int64(len(skipped))
+		}
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	return start, strings.TrimSpace(line), nil
+}
+
+// hashKey returns the first n characters of a hash line
+func hashKey(line string, n int) string {
+	if len(line) < n {
+		return strings.ToUpper(line)
+	}
+	return strings.ToUpper(line[:n])
+}
@@ -0,0 +1,116 @@
+package passwords
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// breachedLines is a sorted hash list with two hashes sharing the prefix 5BAA6,
+// one of which is the SHA-1 of "password".
+var breachedLines = []string{
+	"00000A1B2C3D4E5F60718293A4B5C6D7E8F90123:7",
+	"21BD10000000000000000000000000000000000A:2",
+	"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824",
+	"5BAA6FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1",
+	"7C4A8D09CA3762AF61E59520943DC26494F8941B:123",
+	"FFFFF0000000000000000000000000000000000F:4",
+}
+
+func writeHashFile(t *testing.T, content string) *FileSource {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &FileSource{Path: path}
+}
+
+func TestFileSourceRange(t *testing.T) {
+	files := map[string]string{
+		"trailing newline":    strings.Join(breachedLines, "\n") + "\n",
+		"no trailing newline": strings.Join(breachedLines, "\n"),
+		"CRLF line endings":   strings.Join(breachedLines, "\r\n") + "\r\n",
+	}
+	tests := []struct {
+		prefix string
+		want   map[string]int
+	}{
+		{"00000", map[string]int{"A1B2C3D4E5F60718293A4B5C6D7E8F90123": 7}},
+		{"5baa6", map[string]int{"1E4C9B93F3F0682250B6CF8331B7EE68FD8": 9545824, "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF": 1}},
+		{"FFFFF", map[string]int{"0000000000000000000000000000000000F": 4}},
+		{"00001", map[string]int{}},
+		{"5BAA5", map[string]int{}},
+		{"FFFFE", map[string]int{}},
+	}
+
+	for name, content := range files {
+		source := writeHashFile(t, content)
+		for _, tt := range tests {
+			got, err := source.Range(tt.prefix)
+			if err != nil {
+				t.Fatalf("%s: Range(%s): %v", name, tt.prefix, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: Range(%s) = %v, want %v", name, tt.prefix, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestFileSourceRangeOfEmptyFile(t *testing.T) {
+	got, err := writeHashFile(t, "").Range("5BAA6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("Range of an empty file = %v", got)
+	}
+}
+
+func TestLineAt(t *testing.T) {
+	source := writeHashFile(t, "AAA:1\nBBB:2\nCCC:3")
+	f, err := os.Open(source.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		offset    int64
+		wantStart int64
+		wantLine  string
+	}{
+		{0, 0, "AAA:1"},   // first line
+		{3, 6, "BBB:2"},   // middle of the first line
+		{6, 6, "BBB:2"},   // start of a line
+		{12, 12, "CCC:3"}, // last line, without a trailing newline
+		{14, 17, ""},      // middle of the last line
+		{17, 17, ""},      // end of the file
+	}
+	for _, tt := range tests {
+		start, line, err := lineAt(f, tt.offset)
+		if err != nil {
+			t.Fatalf("lineAt(%d): %v", tt.offset, err)
+		}
+		if start != tt.wantStart || line != tt.wantLine {
+			t.Errorf("lineAt(%d) = %d, %q, want %d, %q", tt.offset, start, line, tt.wantStart, tt.wantLine)
+		}
+	}
+}
+
+func TestIsBreached(t *testing.T) {
+	source := writeHashFile(t, strings.Join(breachedLines, "\n"))
+
+	for password, want := range map[string]bool{"password": true, "123456": true, "Correct-Horse-9": false} {
+		got, err := IsBreached(source, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("IsBreached(%q) = %v, want %v", password, got, want)
+		}
+	}
+}
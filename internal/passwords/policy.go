@@ -0,0 +1,203 @@
+package passwords
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Violation codes reported by Policy.Check
+const (
+	CodeTooShort         = "too_short"
+	CodeMissingLowercase = "missing_lowercase"
+	CodeMissingUppercase = "missing_uppercase"
+	CodeMissingDigit     = "missing_digit"
+	CodeMissingSymbol    = "missing_symbol"
+	CodeContainsPersonal = "contains_personal_info"
+	CodeReused           = "reused"
+	CodeBreached         = "breached"
+)
+
+// Violation is one rule of the policy a password breaks.
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password breaks.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+// Policy describes what a new password must look like.
+type Policy struct {
+	MinLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// History is how many previous passwords cannot be reused
+	History int
+	// Breached is consulted for known leaked passwords when set
+	Breached RangeSource
+}
+
+// PolicyFromEnv builds the policy from the environment:
+//
+//	PASSWORD_MIN_LENGTH      minimum number of characters (default 8)
+//	PASSWORD_REQUIRE         required character classes out of lower,upper,digit,symbol (default "lower,upper,digit", "none" for none)
+//	PASSWORD_HISTORY         number of previous passwords that cannot be reused (default 5)
+//	BREACHED_PASSWORDS_FILE  sorted SHA-1 hash list to reject leaked passwords (disabled when empty)
+func PolicyFromEnv() (*Policy, error) {
+	policy := &Policy{MinLength: 8, History: 5}
+
+	if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH %q", v)
+		}
+		policy.MinLength = n
+	}
+	if v := os.Getenv("PASSWORD_HISTORY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid PASSWORD_HISTORY %q", v)
+		}
+		policy.History = n
+	}
+
+	classes := os.Getenv("PASSWORD_REQUIRE")
+	if classes == "" {
+		classes = "lower,upper,digit"
+	}
+	if classes != "none" {
+		for _, class := range strings.Split(classes, ",") {
+			switch strings.TrimSpace(class) {
+			case "lower":
+				policy.RequireLower = true
+			case "upper":
+				policy.RequireUpper = true
+			case "digit":
+				policy.RequireDigit = true
+			case "symbol":
+				policy.RequireSymbol = true
+			default:
+				return nil, fmt.Errorf("invalid PASSWORD_REQUIRE class %q", class)
+			}
+		}
+	}
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("BREACHED_PASSWORDS_FILE: %w", err)
+		}
+		policy.Breached = &FileSource{Path: path}
+	}
+	return policy, nil
+}
+
+// Check reports every rule the password breaks. personal holds values the password must not
+// contain, such as the email and name; previousHashes are bcrypt hashes of earlier passwords,
+// newest first.
+func (p *Policy) Check(password string, personal []string, previousHashes []string) error {
+	var violations []Violation
+	add := func(code string, message string) {
+		violations = append(violations, Violation{Code: code, Message: message})
+	}
+
+	if len([]rune(password)) < p.MinLength {
+		add(CodeTooShort, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		add(CodeMissingLowercase, "must contain a lowercase letter")
+	}
+	if p.RequireUpper && !upper {
+		add(CodeMissingUppercase, "must contain an uppercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(CodeMissingDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(CodeMissingSymbol, "must contain a symbol")
+	}
+
+	if containsPersonalInfo(password, personal) {
+		add(CodeContainsPersonal, "must not contain your email or name")
+	}
+
+	// The current password counts as the most recent of the History previous ones
+	if len(previousHashes) > p.History {
+		previousHashes = previousHashes[:p.History]
+	}
+	for _, hash := range previousHashes {
+		if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			add(CodeReused, fmt.Sprintf("must not be one of your last %d passwords", p.History))
+			break
+		}
+	}
+
+	// Only worth a lookup if the password is otherwise acceptable
+	if p.Breached != nil && len(violations) == 0 {
+		breached, err := IsBreached(p.Breached, password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			add(CodeBreached, "has appeared in a data breach, choose a different one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsPersonalInfo reports whether the password contains the email, its local part or
+// any word of the name. Parts shorter than 3 characters are ignored.
+func containsPersonalInfo(password string, personal []string) bool {
+	lowered := strings.ToLower(password)
+
+	var parts []string
+	for _, value := range personal {
+		value = strings.ToLower(value)
+		parts = append(parts, value)
+		if local, _, found := strings.Cut(value, "@"); found {
+			parts = append(parts, local)
+		}
+		parts = append(parts, strings.Fields(value)...)
+	}
+
+	for _, part := range parts {
+		if len(part) >= 3 && strings.Contains(lowered, part) {
+			return true
+		}
+	}
+	return false
+}
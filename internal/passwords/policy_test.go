@@ -0,0 +1,46 @@
+package passwords
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashAll(t *testing.T, passwords ...string) []string {
+	t.Helper()
+	hashes := make([]string, len(passwords))
+	for i, password := range passwords {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[i] = string(hash)
+	}
+	return hashes
+}
+
+func TestPolicyHistory(t *testing.T) {
+	policy := &Policy{MinLength: 8, History: 3}
+	previous := hashAll(t, "Current-Pass-1", "Older-Pass-2", "Oldest-Pass-3", "Forgotten-Pass-4")
+
+	for _, password := range []string{"Current-Pass-1", "Older-Pass-2", "Oldest-Pass-3"} {
+		err := policy.Check(password, nil, previous)
+		var policyErr *PolicyError
+		if !errors.As(err, &policyErr) || len(policyErr.Violations) != 1 || policyErr.Violations[0].Code != CodeReused {
+			t.Fatalf("Check(%q) = %v, want a reuse violation", password, err)
+		}
+		if want := "must not be one of your last 3 passwords"; policyErr.Violations[0].Message != want {
+			t.Errorf("message = %q, want %q", policyErr.Violations[0].Message, want)
+		}
+	}
+
+	if err := policy.Check("Forgotten-Pass-4", nil, previous); err != nil {
+		t.Errorf("password older than the history: %v", err)
+	}
+
+	policy.History = 0
+	if err := policy.Check("Current-Pass-1", nil, previous); err != nil {
+		t.Errorf("reuse without history: %v", err)
+	}
+}
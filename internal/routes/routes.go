@@ -3,11 +3,15 @@ package routes
 import (
 	"github.com/alpha-154/crud-go-gin/internal/controllers"
 	"github.com/alpha-154/crud-go-gin/internal/middlewares"
+	"github.com/alpha-154/crud-go-gin/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(router *gin.Engine) {
+	// Public keys for validating our tokens
+	router.GET("/.well-known/jwks.json", controllers.GetJWKS)
+
 	api := router.Group("/api")
 
 	// Auth routes
@@ -16,7 +20,28 @@ func SetupRoutes(router *gin.Engine) {
 		auth.POST("/signup", controllers.SignUp)
 		auth.POST("/signin", controllers.SignIn)
 		auth.POST("/refresh", controllers.RefreshToken)
-		auth.POST("/logout/:user_id", controllers.Logout)
+		auth.POST("/logout", controllers.Logout)
+		auth.POST("/password/forgot", controllers.ForgotPassword)
+		auth.POST("/password/reset", controllers.ResetPassword)
+		auth.GET("/verify", controllers.VerifyEmail)
+		auth.POST("/verify/resend", controllers.ResendVerification)
+		auth.GET("/email/confirm", controllers.ConfirmEmailChange)
+
+		// Social login
+		auth.GET("/oauth/providers", controllers.GetOAuthProviders)
+		auth.GET("/oauth/:provider/start", controllers.StartOAuth)
+		auth.GET("/oauth/:provider/callback", controllers.OAuthCallback)
+
+		// Two-factor authentication
+		auth.POST("/mfa/verify", controllers.VerifyMFA)
+		auth.POST("/mfa/enroll", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.EnrollMFA)
+		auth.POST("/mfa/confirm", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.ConfirmMFA)
+		auth.POST("/mfa/disable", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.DisableMFA)
+
+		// Session management for the signed-in user
+		auth.GET("/sessions", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.GetSessions)
+		auth.DELETE("/sessions", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.RevokeAllSessions)
+		auth.DELETE("/sessions/:id", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.RevokeSession)
 	}
 
 	// Protected routes
@@ -24,14 +49,66 @@ func SetupRoutes(router *gin.Engine) {
 	protected.Use(middlewares.AuthMiddleware())
 	{
 		// User routes
-		protected.GET("/users/:id", controllers.GetUser)
-		protected.GET("/users", middlewares.AdminOnly(), controllers.GetAllUsers)
+		protected.GET("/users/:id", middlewares.RequireSelfOrPermission("id", models.PermissionUsersRead), controllers.GetUser)
+		protected.GET("/users", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionUsersRead), controllers.GetAllUsers)
+		protected.POST("/users/:id/logout", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionUsersWrite), controllers.LogoutUser)
+		protected.POST("/users/:id/unlock", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionUsersWrite), controllers.UnlockUser)
+		protected.PUT("/users/:id/role", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionRolesManage), controllers.SetUserRole)
+		protected.POST("/users", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionUsersWrite), controllers.CreateUser)
+		protected.PUT("/users/:id", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionUsersWrite), controllers.UpdateUser)
+		protected.DELETE("/users/:id", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionUsersWrite), controllers.DeleteUser)
+		protected.POST("/users/:id/disable", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionUsersWrite), controllers.DisableUser)
+		protected.POST("/users/:id/enable", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionUsersWrite), controllers.EnableUser)
+		protected.POST("/users/:id/password", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionUsersWrite), controllers.SetUserPassword)
+		protected.GET("/users/:id/sessions", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionUsersRead), controllers.GetUserSessions)
+		protected.POST("/users/:id/impersonate", middlewares.RequireUserSession(), middlewares.RequirePermission(models.PermissionUsersImpersonate), controllers.ImpersonateUser)
+
+		// Role routes
+		protected.GET("/roles", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionRolesManage), controllers.GetRoles)
+		protected.POST("/roles", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionRolesManage), controllers.CreateRole)
+		protected.PUT("/roles/:name", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionRolesManage), controllers.UpdateRole)
+		protected.DELETE("/roles/:name", middlewares.RejectImpersonation(), middlewares.RequirePermission(models.PermissionRolesManage), controllers.DeleteRole)
 
 		// Restaurant routes
-		protected.POST("/restaurants", controllers.CreateRestaurant)
-		protected.GET("/restaurants", controllers.GetAllRestaurants)
-		protected.GET("/restaurants/:id", controllers.GetRestaurant)
-		protected.PUT("/restaurants/:id", controllers.UpdateRestaurant)
-		protected.DELETE("/restaurants/:id", controllers.DeleteRestaurant)
+		protected.POST("/restaurants", middlewares.RequirePermission(models.PermissionRestaurantsWrite), middlewares.RequireVerifiedEmail(), controllers.CreateRestaurant)
+		protected.POST("/restaurants/import", middlewares.RequirePermission(models.PermissionRestaurantsWrite), middlewares.RequireVerifiedEmail(), controllers.ImportRestaurants)
+		protected.GET("/restaurants", middlewares.RequirePermission(models.PermissionRestaurantsRead), controllers.GetAllRestaurants)
+		protected.GET("/restaurants/search", middlewares.RequirePermission(models.PermissionRestaurantsRead), controllers.SearchRestaurants)
+		protected.GET("/restaurants/nearby", middlewares.RequirePermission(models.PermissionRestaurantsRead), controllers.GetNearbyRestaurants)
+		protected.GET("/restaurants/within", middlewares.RequirePermission(models.PermissionRestaurantsRead), controllers.GetRestaurantsWithin)
+		protected.GET("/restaurants/:id", middlewares.RequirePermission(models.PermissionRestaurantsRead), controllers.GetRestaurant)
+		protected.PUT("/restaurants/:id", middlewares.RequirePermission(models.PermissionRestaurantsWrite), middlewares.RequireVerifiedEmail(), controllers.UpdateRestaurant)
+		protected.DELETE("/restaurants/:id", middlewares.RequirePermission(models.PermissionRestaurantsWrite), middlewares.RequireVerifiedEmail(), controllers.DeleteRestaurant)
+		protected.POST("/restaurants/:id/managers", middlewares.RequirePermission(models.PermissionRestaurantsWrite), middlewares.RequireVerifiedEmail(), controllers.AddRestaurantManager)
+		protected.DELETE("/restaurants/:id/managers/:user_id", middlewares.RequirePermission(models.PermissionRestaurantsWrite), middlewares.RequireVerifiedEmail(), controllers.RemoveRestaurantManager)
+		protected.POST("/restaurants/:id/transfer", middlewares.RequirePermission(models.PermissionRestaurantsWrite), middlewares.RequireVerifiedEmail(), controllers.TransferRestaurantOwnership)
+
+		// Current user routes
+		protected.GET("/me", controllers.GetMe)
+		protected.PATCH("/me", middlewares.RequireUserSession(), controllers.UpdateMe)
+		protected.PUT("/me/password", middlewares.RequireUserSession(), controllers.ChangePassword)
+		protected.POST("/me/email", middlewares.RequireUserSession(), controllers.ChangeEmail)
+		protected.GET("/me/restaurants", controllers.GetMyRestaurants)
+		protected.GET("/me/api-keys", middlewares.RequireUserSession(), controllers.GetAPIKeys)
+		protected.POST("/me/api-keys", middlewares.RequireUserSession(), controllers.CreateAPIKey)
+		protected.DELETE("/me/api-keys/:id", middlewares.RequireUserSession(), controllers.RevokeAPIKey)
+		protected.GET("/me/oauth/consents", middlewares.RequireUserSession(), controllers.GetOAuthConsents)
+		protected.DELETE("/me/oauth/consents/:client_id", middlewares.RequireUserSession(), controllers.RevokeOAuthConsent)
+	}
+
+	// OAuth authorization server for third-party apps
+	oauthServer := api.Group("/oauth")
+	{
+		// Endpoints called by the apps, authenticated with their client credentials
+		oauthServer.POST("/token", controllers.OAuthToken)
+		oauthServer.POST("/introspect", controllers.IntrospectToken)
+		oauthServer.POST("/revoke", controllers.RevokeToken)
+
+		// Endpoints called by our front end for the signed-in user
+		oauthServer.GET("/authorize", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.Authorize)
+		oauthServer.POST("/authorize", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.Consent)
+		oauthServer.GET("/clients", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.GetOAuthClients)
+		oauthServer.POST("/clients", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.RegisterOAuthClient)
+		oauthServer.DELETE("/clients/:id", middlewares.AuthMiddleware(), middlewares.RequireUserSession(), controllers.DeleteOAuthClient)
 	}
 }
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var apiKeyCollection *mongo.Collection
+
+func getAPIKeyCollection() *mongo.Collection {
+	if apiKeyCollection == nil {
+		apiKeyCollection = config.GetCollection(config.DB, "api_keys")
+	}
+	return apiKeyCollection
+}
+
+// API keys look like "ak_<key id>_<secret>"
+const apiKeyPrefix = "ak_"
+
+var errInvalidAPIKey = errors.New("invalid api key")
+
+// CreateAPIKey issues a new key for the user. Scopes are limited to permissions the user currently has.
+func CreateAPIKey(userID string, input dto.CreateAPIKeyInput) (*dto.CreatedAPIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	granted, err := GetUserPermissions(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePermissions(input.Scopes); err != nil {
+		return nil, err
+	}
+	for _, scope := range input.Scopes {
+		if scope == models.PermissionAll || !models.HasPermission(granted, scope) {
+			return nil, errors.New("scope not allowed: " + scope)
+		}
+	}
+
+	keyID, err := helpers.GenerateRandomID()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := helpers.GenerateRandomID()
+	if err != nil {
+		return nil, err
+	}
+	keyID = keyID[:16]
+	key := apiKeyPrefix + keyID + "_" + secret
+
+	apiKey := models.APIKey{
+		ID:        primitive.NewObjectID(),
+		KeyID:     keyID,
+		UserID:    userID,
+		Name:      input.Name,
+		Prefix:    key[:len(apiKeyPrefix)+8],
+		KeyHash:   helpers.HashToken(key),
+		Scopes:    input.Scopes,
+		CreatedAt: time.Now(),
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := apiKey.CreatedAt.AddDate(0, 0, input.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if _, err := getAPIKeyCollection().InsertOne(ctx, apiKey); err != nil {
+		return nil, err
+	}
+	return &dto.CreatedAPIKey{Key: key, APIKey: &apiKey}, nil
+}
+
+// GetAPIKeys lists the user's keys that are not revoked
+func GetAPIKeys(userID string) ([]models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := getAPIKeyCollection().Find(
+		ctx,
+		bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		options.Find().SetSort(bson.M{"created_at": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of the user's keys
+func RevokeAPIKey(userID string, keyID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := getAPIKeyCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": userID, "key_id": keyID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("api key not found")
+	}
+	return nil
+}
+
+// AuthenticateAPIKey checks a key presented in an "Authorization: ApiKey ..." header
+// and returns it together with its active owner.
+func AuthenticateAPIKey(key string) (*models.APIKey, *models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	parts := strings.SplitN(strings.TrimPrefix(key, apiKeyPrefix), "_", 2)
+	if !strings.HasPrefix(key, apiKeyPrefix) || len(parts) != 2 {
+		return nil, nil, errInvalidAPIKey
+	}
+
+	var apiKey models.APIKey
+	err := getAPIKeyCollection().FindOne(ctx, bson.M{"key_id": parts[0]}).Decode(&apiKey)
+	if err != nil {
+		return nil, nil, errInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(helpers.HashToken(key))) != 1 {
+		return nil, nil, errInvalidAPIKey
+	}
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt)) {
+		return nil, nil, errors.New("api key expired or revoked")
+	}
+
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": apiKey.UserID}).Decode(&user); err != nil {
+		return nil, nil, errInvalidAPIKey
+	}
+	if user.Disabled {
+		return nil, nil, errors.New("account disabled")
+	}
+
+	_, err = getAPIKeyCollection().UpdateOne(ctx, bson.M{"key_id": apiKey.KeyID}, bson.M{"$set": bson.M{"last_used_at": time.Now()}})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &apiKey, &user, nil
+}
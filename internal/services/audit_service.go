@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var auditLogCollection *mongo.Collection
+
+func getAuditLogCollection() *mongo.Collection {
+	if auditLogCollection == nil {
+		auditLogCollection = config.GetCollection(config.DB, "audit_logs")
+	}
+	return auditLogCollection
+}
+
+// recordAudit stores an audit log entry.
+func recordAudit(ctx context.Context, entry models.AuditLog) error {
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+
+	log.Printf("audit: action=%s actor=%s target=%s/%s", entry.Action, entry.ActorID, entry.TargetType, entry.TargetID)
+	_, err := getAuditLogCollection().InsertOne(ctx, entry)
+	return err
+}
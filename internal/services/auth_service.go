@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"regexp"
 	"time"
 
 	"github.com/alpha-154/crud-go-gin/internal/config"
@@ -26,13 +28,13 @@ func getUserCollection() *mongo.Collection {
 	return userCollection
 }
 
-// SignUp registers a new user and generates the required tokens.
-func SignUp(user models.User) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// dummyPasswordHash is compared against when the email is unknown
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
 
+// createUser hashes the password and stores a new user with the given role.
+func createUser(ctx context.Context, name string, email string, password string, role string) (*models.User, error) {
 	// Check if email exists
-	emailTaken, err := helpers.IsEmailTaken(ctx, user.Email)
+	emailTaken, err := helpers.IsEmailTaken(ctx, email)
 	if err != nil {
 		return nil, fmt.Errorf("error checking email: %w", err)
 	}
@@ -41,20 +43,22 @@ func SignUp(user models.User) (*models.User, error) {
 	}
 
 	// Hash password using the helper function
-	hashedPassword, err := helpers.HashPassword(user.Password)
+	hashedPassword, err := helpers.HashPassword(password)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set user fields
-	user.ID = primitive.NewObjectID()
-	user.UserID = user.ID.Hex()
-	user.Password = hashedPassword
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
-	if user.Role == "" {
-		user.Role = "user"
+	user := models.User{
+		ID:        primitive.NewObjectID(),
+		Name:      name,
+		Email:     email,
+		Password:  hashedPassword,
+		Role:      role,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
 	}
+	user.UserID = user.ID.Hex()
 
 	// Save user to the database
 	_, err = getUserCollection().InsertOne(ctx, user)
@@ -62,51 +66,174 @@ func SignUp(user models.User) (*models.User, error) {
 		return nil, err
 	}
 
-	// Don't return the password in the response
-	user.Password = ""
-
-	// Return the user with the refresh token
 	return &user, nil
 }
 
-// SignIn authenticates a user and generates the required tokens
-func SignIn(input dto.SignInInput) (*dto.SignInServiceResponse, error) {
+// SignUp registers a new user. Self-registered users always get the default role.
+func SignUp(input dto.SignUpInput) (*models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var user models.User
-	err := getUserCollection().FindOne(ctx, bson.M{"email": input.Email}).Decode(&user)
+	if err := validateNewPassword(input.Password, input.Email, input.Name); err != nil {
+		return nil, err
+	}
+
+	user, err := createUser(ctx, input.Name, input.Email, input.Password, models.RoleUser)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, err
 	}
 
-	// Verify password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
+	// The account works without the email, the user can ask for a new one
+	if err := sendVerificationEmail(ctx, user); err != nil {
+		log.Println("Failed to send verification email:", err)
+	}
+
+	return user, nil
+}
+
+// SetUserRole changes the role of a user on behalf of an admin. The admin must hold every
+// permission of both the user's current role and the new one.
+func SetUserRole(userID string, role string, actorID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	exists, err := roleExists(ctx, role)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return err
+	}
+	if !exists {
+		return errors.New("role not found")
+	}
+	if err := requireActorOutranks(actorID, userID); err != nil {
+		return err
+	}
+	permissions, err := rolePermissions(ctx, role)
+	if err != nil {
+		return err
+	}
+	if err := requireActorPermissions(actorID, permissions); err != nil {
+		return err
+	}
+
+	return setUserRole(ctx, userID, role)
+}
+
+// setUserRole changes the role of a user and signs them out so new tokens carry the role.
+func setUserRole(ctx context.Context, userID string, role string) error {
+	result, err := getUserCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"role": role, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+
+	return revokeUserSessions(ctx, userID, "role_changed")
+}
+
+// BootstrapAdmin creates an admin account, or promotes the existing account with that email.
+// It is meant for setting up the first admin from the command line.
+func BootstrapAdmin(name string, email string, password string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var existing models.User
+	err := getUserCollection().FindOne(ctx, bson.M{"email": email}).Decode(&existing)
+	if err == nil {
+		if err := setUserRole(ctx, existing.UserID, models.RoleAdmin); err != nil {
+			return nil, err
+		}
+		existing.Role = models.RoleAdmin
+		return &existing, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if err := validateNewPassword(password, email, name); err != nil {
+		return nil, err
 	}
 
-	// Generate access & refresh token
-	token, err := helpers.GenerateTokenPair(user.UserID, user.Role)
+	user, err := createUser(ctx, name, email, password, models.RoleAdmin)
 	if err != nil {
 		return nil, err
 	}
 
-	// Update refresh token in database
-	updateResult, err := getUserCollection().UpdateOne(
+	// The operator vouches for the address
+	now := time.Now()
+	_, err = getUserCollection().UpdateOne(
 		ctx,
-		bson.M{"user_id": user.UserID}, // Ensure user.UserID is a string
-		bson.M{"$set": bson.M{"refresh_token": token.RefreshToken}},
+		bson.M{"user_id": user.UserID},
+		bson.M{"$set": bson.M{"email_verified": true, "email_verified_at": now}},
 	)
 	if err != nil {
 		return nil, err
 	}
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+	return user, nil
+}
+
+// SignIn authenticates a user and starts a new session for the client device
+func SignIn(input dto.SignInInput, client dto.ClientInfo) (*dto.SignInServiceResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Refuse locked out accounts and clients before touching the password
+	if err := checkLockout(ctx, accountAttemptKey(input.Email), ipAttemptKey(client.IP)); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err := getUserCollection().FindOne(ctx, bson.M{"email": input.Email}).Decode(&user)
+	if err != nil {
+		// Spend the same time as for a wrong password so unknown emails can't be told apart
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(input.Password))
+		if err := recordFailedSignIn(ctx, input.Email, client.IP); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid credentials")
+	}
+
+	// Verify password
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
+	if err != nil {
+		if err := recordFailedSignIn(ctx, input.Email, client.IP); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid credentials")
+	}
+
+	if user.Disabled {
+		return nil, errors.New("account disabled")
+	}
 
-	fmt.Println("Matched count:", updateResult.MatchedCount, "Modified count:", updateResult.ModifiedCount)
+	if config.EmailVerificationMode() == config.EmailVerificationSignIn && !user.EmailVerified {
+		return nil, errors.New("email not verified")
+	}
+
+	// Users with two-factor authentication get a challenge instead of tokens.
+	// Their failure counter is only reset once the second factor checks out.
+	if user.MFAEnabled {
+		mfaToken, err := createMFAChallenge(ctx, &user)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.SignInServiceResponse{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+
+	if err := resetAccountFailures(ctx, input.Email); err != nil {
+		return nil, err
+	}
 
-	// If no document was modified, return an error
-	if updateResult.MatchedCount == 0 {
-		return nil, errors.New("failed to update refresh token")
+	// Generate access & refresh token for a new session
+	client.Device = input.Device
+	token, err := startSession(ctx, &user, client)
+	if err != nil {
+		return nil, err
 	}
 
 	return &dto.SignInServiceResponse{
@@ -126,16 +253,27 @@ func GetUserByID(userID string) (*models.User, error) {
 		return nil, err
 	}
 
-	user.Password = "" // Remove password from response
 	return &user, nil
 }
 
-// GetAllUsers retrieves all users
-func GetAllUsers() ([]models.User, error) {
+// GetAllUsers retrieves all users matching the search query
+func GetAllUsers(query dto.UserSearchQuery) ([]models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := getUserCollection().Find(ctx, bson.M{})
+	filter := bson.M{}
+	if query.Query != "" {
+		pattern := bson.M{"$regex": regexp.QuoteMeta(query.Query), "$options": "i"}
+		filter["$or"] = []bson.M{{"name": pattern}, {"email": pattern}, {"user_id": query.Query}}
+	}
+	if query.Role != "" {
+		filter["role"] = query.Role
+	}
+	if query.Disabled != nil {
+		filter["disabled"] = *query.Disabled
+	}
+
+	cursor, err := getUserCollection().Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -146,22 +284,18 @@ func GetAllUsers() ([]models.User, error) {
 		return nil, err
 	}
 
-	// Remove passwords from response
-	for i := range users {
-		users[i].Password = ""
-	}
-
 	return users, nil
 }
 
-// InvalidateUserTokens invalidates the refresh token for a user
-func InvalidateUserTokens(userID string) error {
+// InvalidateUserTokens revokes every session and access token of a user on behalf of an admin
+// who holds every permission of the user
+func InvalidateUserTokens(userID string, actorID string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := getUserCollection().UpdateOne(ctx, bson.M{"user_id": userID}, bson.M{"$set": bson.M{"refresh_token": ""}})
-	if err != nil {
+	if err := requireActorOutranks(actorID, userID); err != nil {
 		return err
 	}
-	return nil
+
+	return revokeUserSessions(ctx, userID, "admin_logout")
 }
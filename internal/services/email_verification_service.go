@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/mailer"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+
+	// Minimum delay between two verification emails for the same user
+	verificationResendInterval = time.Minute
+)
+
+// sendVerificationEmail emails a verification link to the user's address.
+func sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := createUserToken(ctx, user.UserID, models.TokenPurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return sendMail(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in 24 hours.\n\n%s\n",
+			user.Name, appLink("/verify-email", token)),
+	})
+}
+
+// VerifyEmail marks the email of the token's user as verified.
+func VerifyEmail(token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	record, err := consumeUserToken(ctx, token, models.TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result, err := getUserCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": record.UserID},
+		bson.M{"$set": bson.M{"email_verified": true, "email_verified_at": now, "updated_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// ResendVerificationEmail sends a new verification email in the background, at most once per
+// minute per user. Unknown, already verified and throttled emails are silently ignored, and the
+// caller never waits, so the endpoint does not reveal which accounts exist.
+func ResendVerificationEmail(email string) {
+	go func() {
+		if err := resendVerificationEmail(email); err != nil {
+			log.Println("Failed to resend verification email:", err)
+		}
+	}()
+}
+
+func resendVerificationEmail(email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := getUserCollection().FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	recent, err := getUserTokenCollection().CountDocuments(ctx, bson.M{
+		"user_id":    user.UserID,
+		"purpose":    models.TokenPurposeEmailVerification,
+		"created_at": bson.M{"$gt": time.Now().Add(-verificationResendInterval)},
+	})
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		return nil
+	}
+
+	return sendVerificationEmail(ctx, &user)
+}
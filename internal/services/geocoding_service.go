@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/geocoding"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	geocodeWorkers       = 2
+	geocodeQueueSize     = 100
+	geocodeMaxAttempts   = 5
+	geocodeRetryDelay    = 30 * time.Second // doubled after every failed attempt
+	geocodeSweepInterval = 5 * time.Minute  // how often pending restaurants that missed the queue are picked up
+)
+
+var (
+	geocoderInstance geocoding.Geocoder
+	geocodeJobs      chan geocodeJob
+
+	// Restaurant and address pairs queued or waiting for a retry, so the sweep doesn't queue them twice
+	geocodeInFlight sync.Map
+)
+
+// geocodeJob asks for the coordinates of a restaurant address.
+type geocodeJob struct {
+	RestaurantID string
+	Address      string
+	Attempt      int
+}
+
+// SetGeocoder replaces the geocoder used by the services, e.g. in tests.
+// It must be called before StartGeocoder.
+func SetGeocoder(g geocoding.Geocoder) {
+	geocoderInstance = g
+}
+
+// StartGeocoder starts the workers that fill in restaurant locations from their addresses,
+// and a sweep that queues restaurants still pending from a previous run or from a full queue.
+// Geocoding stays disabled when no geocoder is configured.
+func StartGeocoder() error {
+	if geocoderInstance == nil {
+		g, err := geocoding.NewFromEnv()
+		if err != nil {
+			return err
+		}
+		geocoderInstance = g
+	}
+	if geocoderInstance == nil {
+		return nil
+	}
+
+	geocodeJobs = make(chan geocodeJob, geocodeQueueSize)
+	for i := 0; i < geocodeWorkers; i++ {
+		go runGeocodeWorker()
+	}
+	go runGeocodeSweeper()
+
+	return enqueuePendingGeocodes()
+}
+
+func runGeocodeSweeper() {
+	ticker := time.NewTicker(geocodeSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := enqueuePendingGeocodes(); err != nil {
+			log.Println("Failed to queue pending restaurants for geocoding:", err)
+		}
+	}
+}
+
+// enqueuePendingGeocodes queues every pending restaurant that is not queued already.
+func enqueuePendingGeocodes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := getRestaurantCollection().Find(ctx, bson.M{"geocode_status": models.GeocodePending})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var restaurant models.Restaurant
+		if err := cursor.Decode(&restaurant); err != nil {
+			return err
+		}
+		enqueueGeocode(restaurant.RestaurantID, restaurant.Address, 1)
+	}
+	return cursor.Err()
+}
+
+// geocodingEnabled reports whether new addresses are geocoded.
+func geocodingEnabled() bool {
+	return geocodeJobs != nil
+}
+
+func (job geocodeJob) key() string {
+	return job.RestaurantID + "\x00" + job.Address
+}
+
+// enqueueGeocode queues a job unless it is queued already. It never blocks: when the queue
+// is full the restaurant stays pending and the sweep queues it later.
+func enqueueGeocode(restaurantID string, address string, attempt int) {
+	if !geocodingEnabled() {
+		return
+	}
+	job := geocodeJob{RestaurantID: restaurantID, Address: address, Attempt: attempt}
+	if _, queued := geocodeInFlight.LoadOrStore(job.key(), true); queued {
+		return
+	}
+	sendGeocodeJob(job)
+}
+
+// sendGeocodeJob hands a job to the workers, or drops it when the queue is full.
+func sendGeocodeJob(job geocodeJob) {
+	select {
+	case geocodeJobs <- job:
+	default:
+		geocodeInFlight.Delete(job.key())
+	}
+}
+
+func runGeocodeWorker() {
+	for job := range geocodeJobs {
+		retrying, err := geocodeRestaurant(job)
+		if err != nil {
+			log.Println("Failed to store geocoding result for restaurant", job.RestaurantID+":", err)
+		}
+		if !retrying {
+			geocodeInFlight.Delete(job.key())
+		}
+	}
+}
+
+// geocodeRestaurant resolves the address of a job and stores the outcome, reporting whether
+// a retry was scheduled. Updates only apply while the restaurant still has the job's address
+// and is pending, so results for an address that was changed or replaced by a manual location are dropped.
+func geocodeRestaurant(job geocodeJob) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"restaurant_id":  job.RestaurantID,
+		"address":        job.Address,
+		"geocode_status": models.GeocodePending,
+	}
+
+	result, err := geocoderInstance.Geocode(ctx, job.Address)
+	if errors.Is(err, geocoding.ErrNotFound) {
+		_, err = getRestaurantCollection().UpdateOne(ctx, filter, bson.M{
+			"$set":   bson.M{"geocode_status": models.GeocodeNotFound, "geocoded_at": time.Now()},
+			"$unset": bson.M{"geocode_error": ""},
+		})
+		return false, err
+	}
+	if err != nil {
+		set := bson.M{"geocode_error": err.Error()}
+		retrying := job.Attempt < geocodeMaxAttempts
+		if retrying {
+			retry := geocodeJob{RestaurantID: job.RestaurantID, Address: job.Address, Attempt: job.Attempt + 1}
+			time.AfterFunc(geocodeRetryDelay<<(job.Attempt-1), func() { sendGeocodeJob(retry) })
+		} else {
+			set["geocode_status"] = models.GeocodeFailed
+		}
+		_, err = getRestaurantCollection().UpdateOne(ctx, filter, bson.M{"$set": set})
+		return retrying, err
+	}
+
+	location := models.NewGeoPoint(result.Lng, result.Lat)
+	if !location.Valid() {
+		_, err = getRestaurantCollection().UpdateOne(ctx, filter, bson.M{
+			"$set": bson.M{"geocode_status": models.GeocodeFailed, "geocode_error": "geocoder returned invalid coordinates"},
+		})
+		return false, err
+	}
+	_, err = getRestaurantCollection().UpdateOne(ctx, filter, bson.M{
+		"$set":   bson.M{"location": location, "geocode_status": models.GeocodeDone, "geocoded_at": time.Now()},
+		"$unset": bson.M{"geocode_error": ""},
+	})
+	return false, err
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alpha-154/crud-go-gin/internal/geocoding"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// stubGeocoder answers every address with the same result and error.
+type stubGeocoder struct {
+	result *geocoding.Result
+	err    error
+}
+
+func (g stubGeocoder) Geocode(context.Context, string) (*geocoding.Result, error) {
+	return g.result, g.err
+}
+
+// insertPendingRestaurant stores a restaurant waiting for its address to be geocoded.
+func insertPendingRestaurant(t *testing.T, id string, address string) {
+	t.Helper()
+	_, err := getRestaurantCollection().InsertOne(context.Background(), bson.M{
+		"_id":            primitive.NewObjectID(),
+		"restaurant_id":  id,
+		"name":           "Place",
+		"address":        address,
+		"cuisine":        "italian",
+		"geocode_status": models.GeocodePending,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func findRestaurant(t *testing.T, id string) models.Restaurant {
+	t.Helper()
+	var restaurant models.Restaurant
+	err := getRestaurantCollection().FindOne(context.Background(), bson.M{"restaurant_id": id}).Decode(&restaurant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return restaurant
+}
+
+func TestGeocodeRestaurant(t *testing.T) {
+	tests := []struct {
+		name         string
+		geocoder     stubGeocoder
+		attempt      int
+		wantRetry    bool
+		wantStatus   string
+		wantError    bool
+		wantLocation bool
+	}{
+		{"found", stubGeocoder{result: &geocoding.Result{Lat: 39.8, Lng: -89.6}}, 1, false, models.GeocodeDone, false, true},
+		{"not found", stubGeocoder{err: geocoding.ErrNotFound}, 1, false, models.GeocodeNotFound, false, false},
+		{"invalid coordinates", stubGeocoder{result: &geocoding.Result{Lat: 91, Lng: 0}}, 1, false, models.GeocodeFailed, true, false},
+		{"error is retried", stubGeocoder{err: errors.New("service unavailable")}, geocodeMaxAttempts - 1, true, models.GeocodePending, true, false},
+		{"error on the last attempt", stubGeocoder{err: errors.New("service unavailable")}, geocodeMaxAttempts, false, models.GeocodeFailed, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db.Reset(t)
+			geocoderInstance = tt.geocoder
+			defer func() { geocoderInstance = nil }()
+			insertPendingRestaurant(t, "r1", "1 Market St, Springfield")
+
+			retrying, err := geocodeRestaurant(geocodeJob{RestaurantID: "r1", Address: "1 Market St, Springfield", Attempt: tt.attempt})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if retrying != tt.wantRetry {
+				t.Errorf("retrying = %v, want %v", retrying, tt.wantRetry)
+			}
+			restaurant := findRestaurant(t, "r1")
+			if restaurant.GeocodeStatus != tt.wantStatus {
+				t.Errorf("geocode_status = %q, want %q", restaurant.GeocodeStatus, tt.wantStatus)
+			}
+			if (restaurant.GeocodeError != "") != tt.wantError {
+				t.Errorf("geocode_error = %q", restaurant.GeocodeError)
+			}
+			if (restaurant.Location != nil) != tt.wantLocation {
+				t.Errorf("location = %v", restaurant.Location)
+			}
+		})
+	}
+}
+
+func TestGeocodeRestaurantDropsResultForChangedAddress(t *testing.T) {
+	db.Reset(t)
+	geocoderInstance = stubGeocoder{result: &geocoding.Result{Lat: 39.8, Lng: -89.6}}
+	defer func() { geocoderInstance = nil }()
+	insertPendingRestaurant(t, "r1", "2 Elm Ave, Springfield")
+
+	if _, err := geocodeRestaurant(geocodeJob{RestaurantID: "r1", Address: "1 Market St, Springfield", Attempt: 1}); err != nil {
+		t.Fatal(err)
+	}
+	restaurant := findRestaurant(t, "r1")
+	if restaurant.GeocodeStatus != models.GeocodePending || restaurant.Location != nil {
+		t.Fatalf("result for the old address stored: %q %v", restaurant.GeocodeStatus, restaurant.Location)
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	bsonv2 "go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the services rely on.
+// It is safe to call on every startup.
+func EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := getRefreshTokenCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"jti": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"family_id": 1}},
+		{Keys: bson.M{"user_id": 1}},
+		// Expired refresh tokens are removed by MongoDB
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getSessionCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"session_id": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"user_id": 1}},
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getRevokedTokenCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"jti": 1}, Options: options.Index().SetUnique(true)},
+		// Denylist entries are dropped once the token would have expired anyway
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getUserTokenCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"token_hash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"user_id": 1}},
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getLoginAttemptCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"key": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getRoleCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"name": 1}, Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getRestaurantCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"restaurant_id": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"owner_id": 1}},
+		{Keys: bson.M{"manager_ids": 1}},
+		// Listing sorts and filters, see GetAllRestaurants
+		{Keys: bsonv2.D{{Key: "cuisine", Value: 1}, {Key: "restaurant_id", Value: 1}}},
+		{Keys: bsonv2.D{{Key: "name", Value: 1}, {Key: "restaurant_id", Value: 1}}},
+		// Full-text search, see SearchRestaurants
+		{
+			Keys: bsonv2.D{{Key: "name", Value: "text"}, {Key: "cuisine", Value: "text"}, {Key: "address", Value: "text"}},
+			Options: options.Index().SetName("restaurant_text").
+				SetWeights(bsonv2.D{{Key: "name", Value: 10}, {Key: "cuisine", Value: 5}, {Key: "address", Value: 2}}),
+		},
+		{Keys: bson.M{"search_grams": 1}},
+		{Keys: bson.M{"location": "2dsphere"}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getAuditLogCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"target_id": 1}},
+		{Keys: bson.M{"actor_id": 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getAPIKeyCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"key_id": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"user_id": 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getOAuthStateCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"state_hash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getExternalIdentityCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// Compound keys need an ordered document
+		{Keys: bsonv2.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"user_id": 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getOAuthClientCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"client_id": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"owner_id": 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getOAuthCodeCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"code_hash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getOAuthConsentCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bsonv2.D{{Key: "user_id", Value: 1}, {Key: "client_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"client_id": 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = getOAuthTokenCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"jti": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"client_id": 1}},
+		{Keys: bson.M{"user_id": 1}},
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
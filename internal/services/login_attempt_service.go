@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var loginAttemptCollection *mongo.Collection
+
+func getLoginAttemptCollection() *mongo.Collection {
+	if loginAttemptCollection == nil {
+		loginAttemptCollection = config.GetCollection(config.DB, "login_attempts")
+	}
+	return loginAttemptCollection
+}
+
+const (
+	// Failures allowed before an account or IP address gets locked
+	accountFailureThreshold = 5
+	ipFailureThreshold      = 20
+
+	// The first lockout lasts lockoutBase and doubles with every further failure
+	lockoutBase = time.Minute
+	lockoutMax  = time.Hour
+
+	// Counters are forgotten after this long without failures
+	loginAttemptWindow = 24 * time.Hour
+)
+
+// LockoutError is returned while an account or IP address is locked out.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("too many failed sign-in attempts, try again in %d seconds", int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+func accountAttemptKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkLockout returns a LockoutError if any of the keys is currently locked.
+func checkLockout(ctx context.Context, keys ...string) error {
+	cursor, err := getLoginAttemptCollection().Find(ctx, bson.M{
+		"key":          bson.M{"$in": keys},
+		"locked_until": bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	var attempts []models.LoginAttempt
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return err
+	}
+
+	var retryAfter time.Duration
+	for _, attempt := range attempts {
+		if wait := time.Until(attempt.LockedUntil); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter > 0 {
+		return &LockoutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordLoginFailure increments the failure counter of the key and locks it once over the threshold.
+func recordLoginFailure(ctx context.Context, key string, threshold int) error {
+	now := time.Now()
+	var attempt models.LoginAttempt
+	err := getLoginAttemptCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"key": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"last_failure_at": now, "expires_at": now.Add(loginAttemptWindow)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		return err
+	}
+
+	if attempt.Failures < threshold {
+		return nil
+	}
+
+	// Exponential backoff: 1m, 2m, 4m, ... capped at lockoutMax
+	lockout := lockoutMax
+	if exponent := attempt.Failures - threshold; exponent < 16 {
+		lockout = min(lockoutBase*time.Duration(1<<exponent), lockoutMax)
+	}
+	_, err = getLoginAttemptCollection().UpdateOne(
+		ctx,
+		bson.M{"key": key},
+		bson.M{"$set": bson.M{"locked_until": now.Add(lockout)}},
+	)
+	return err
+}
+
+// recordFailedSignIn counts a failed sign-in for both the account and the client IP.
+func recordFailedSignIn(ctx context.Context, email string, ip string) error {
+	if err := recordLoginFailure(ctx, accountAttemptKey(email), accountFailureThreshold); err != nil {
+		return err
+	}
+	return recordLoginFailure(ctx, ipAttemptKey(ip), ipFailureThreshold)
+}
+
+// resetAccountFailures clears the failure counter of an account after a successful sign-in.
+// The IP counter is left alone so one valid account cannot be used to reset it.
+func resetAccountFailures(ctx context.Context, email string) error {
+	_, err := getLoginAttemptCollection().DeleteOne(ctx, bson.M{"key": accountAttemptKey(email)})
+	return err
+}
+
+// UnlockUser clears the failed sign-in counter and lockout of a user's account on behalf of an admin
+// who holds every permission of the user
+func UnlockUser(userID string, actorID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := requireActorOutranks(actorID, userID); err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil {
+		return err
+	}
+	return resetAccountFailures(ctx, user.Email)
+}
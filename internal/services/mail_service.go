@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/alpha-154/crud-go-gin/internal/mailer"
+)
+
+var mailerInstance mailer.Mailer
+
+func getMailer() mailer.Mailer {
+	if mailerInstance == nil {
+		m, err := mailer.NewFromEnv()
+		if err != nil {
+			log.Fatal("Failed to configure mailer:", err)
+		}
+		mailerInstance = m
+	}
+	return mailerInstance
+}
+
+// SetMailer replaces the mailer used by the services, e.g. in tests.
+func SetMailer(m mailer.Mailer) {
+	mailerInstance = m
+}
+
+// sendMail delivers a message through the configured mailer.
+func sendMail(ctx context.Context, msg mailer.Message) error {
+	return getMailer().Send(ctx, msg)
+}
+
+// appLink builds a link to a page of the frontend at APP_URL carrying a token.
+func appLink(path string, token string) string {
+	base := strings.TrimSuffix(os.Getenv("APP_URL"), "/")
+	if base == "" {
+		base = "http://localhost:3000"
+	}
+	return base + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"os"
+	"testing"
+
+	"github.com/alpha-154/crud-go-gin/internal/mongotest"
+)
+
+var db *mongotest.Database
+
+func TestMain(m *testing.M) {
+	db = mongotest.Setup()
+	if db != nil {
+		if err := EnsureIndexes(); err != nil {
+			panic(err)
+		}
+	}
+	code := m.Run()
+	db.Drop()
+	os.Exit(code)
+}
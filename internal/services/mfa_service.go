@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+	mfaRecoveryCodeCount    = 10
+)
+
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "crud-go-gin"
+}
+
+// StartMFAEnrollment generates a pending TOTP secret for the user.
+// It only becomes active once confirmed with a valid code.
+func StartMFAEnrollment(userID string) (*dto.MFAEnrollment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.MFAEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := helpers.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	uri := helpers.TOTPURI(mfaIssuer(), user.Email, secret)
+	png, err := helpers.TOTPQRCode(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = getUserCollection().UpdateOne(ctx, bson.M{"user_id": userID}, bson.M{"$set": bson.M{"mfa_pending_secret": secret}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.MFAEnrollment{Secret: secret, OTPAuthURI: uri, QRCodePNG: png}, nil
+}
+
+// ConfirmMFAEnrollment activates the pending secret and returns the one-time recovery codes.
+// The codes are only stored hashed and cannot be shown again.
+func ConfirmMFAEnrollment(userID string, code string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.MFAPendingSecret == "" {
+		return nil, errors.New("no two-factor enrollment in progress")
+	}
+
+	step, ok := helpers.ValidateTOTP(user.MFAPendingSecret, code, time.Now())
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+
+	codes, err := helpers.GenerateRecoveryCodes(mfaRecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashes = append(hashes, helpers.HashToken(helpers.NormalizeRecoveryCode(c)))
+	}
+
+	_, err = getUserCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{
+			"$set": bson.M{
+				"mfa_enabled":        true,
+				"mfa_secret":         user.MFAPendingSecret,
+				"mfa_recovery_codes": hashes,
+				"mfa_last_step":      step,
+				"updated_at":         time.Now(),
+			},
+			"$unset": bson.M{"mfa_pending_secret": ""},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA turns two-factor authentication off after checking a current code.
+func DisableMFA(userID string, code string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil {
+		return errors.New("user not found")
+	}
+	if !user.MFAEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if err := verifyMFACode(ctx, &user, code); err != nil {
+		return err
+	}
+
+	_, err := getUserCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{
+			"$set":   bson.M{"mfa_enabled": false, "updated_at": time.Now()},
+			"$unset": bson.M{"mfa_secret": "", "mfa_recovery_codes": "", "mfa_last_step": "", "mfa_pending_secret": ""},
+		},
+	)
+	return err
+}
+
+// verifyMFACode accepts a TOTP code that was not used before, or consumes a recovery code.
+func verifyMFACode(ctx context.Context, user *models.User, code string) error {
+	if step, ok := helpers.ValidateTOTP(user.MFASecret, code, time.Now()); ok {
+		// Only move forward in time so a code cannot be replayed
+		result, err := getUserCollection().UpdateOne(
+			ctx,
+			bson.M{"user_id": user.UserID, "mfa_last_step": bson.M{"$lt": step}},
+			bson.M{"$set": bson.M{"mfa_last_step": step}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 1 {
+			return nil
+		}
+		return errors.New("invalid code")
+	}
+
+	hash := helpers.HashToken(helpers.NormalizeRecoveryCode(code))
+	result, err := getUserCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": user.UserID, "mfa_recovery_codes": hash},
+		bson.M{"$pull": bson.M{"mfa_recovery_codes": hash}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 1 {
+		return nil
+	}
+	return errors.New("invalid code")
+}
+
+// createMFAChallenge issues the token a user exchanges, together with a code, for real tokens.
+func createMFAChallenge(ctx context.Context, user *models.User) (string, error) {
+	return createUserToken(ctx, user.UserID, models.TokenPurposeMFAChallenge, mfaChallengeTTL)
+}
+
+// CompleteMFASignIn finishes a two-step sign-in and starts the session.
+func CompleteMFASignIn(input dto.MFAVerifyInput, client dto.ClientInfo) (*dto.SignInServiceResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Count the attempt before checking the code to limit guessing
+	var challenge models.UserToken
+	err := getUserTokenCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"token_hash": helpers.HashToken(input.MFAToken),
+			"purpose":    models.TokenPurposeMFAChallenge,
+			"used_at":    bson.M{"$exists": false},
+			"expires_at": bson.M{"$gt": time.Now()},
+			"attempts":   bson.M{"$lt": mfaChallengeMaxAttempts},
+		},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+	).Decode(&challenge)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("invalid or expired token")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": challenge.UserID}).Decode(&user); err != nil {
+		return nil, errors.New("invalid credentials")
+	}
+	if user.Disabled {
+		return nil, errors.New("account disabled")
+	}
+
+	// Wrong codes count as failed sign-ins, so the lockout covers guessing across challenges
+	if err := checkLockout(ctx, accountAttemptKey(user.Email), ipAttemptKey(client.IP)); err != nil {
+		return nil, err
+	}
+	if err := verifyMFACode(ctx, &user, input.Code); err != nil {
+		if err := recordFailedSignIn(ctx, user.Email, client.IP); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+	if err := resetAccountFailures(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	if _, err := consumeUserToken(ctx, input.MFAToken, models.TokenPurposeMFAChallenge); err != nil {
+		return nil, err
+	}
+
+	client.Device = input.Device
+	token, err := startSession(ctx, &user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.SignInServiceResponse{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+	}, nil
+}
@@ -0,0 +1,515 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	oauthClientCollection  *mongo.Collection
+	oauthCodeCollection    *mongo.Collection
+	oauthConsentCollection *mongo.Collection
+	oauthTokenCollection   *mongo.Collection
+)
+
+func getOAuthClientCollection() *mongo.Collection {
+	if oauthClientCollection == nil {
+		oauthClientCollection = config.GetCollection(config.DB, "oauth_clients")
+	}
+	return oauthClientCollection
+}
+
+func getOAuthCodeCollection() *mongo.Collection {
+	if oauthCodeCollection == nil {
+		oauthCodeCollection = config.GetCollection(config.DB, "oauth_codes")
+	}
+	return oauthCodeCollection
+}
+
+func getOAuthConsentCollection() *mongo.Collection {
+	if oauthConsentCollection == nil {
+		oauthConsentCollection = config.GetCollection(config.DB, "oauth_consents")
+	}
+	return oauthConsentCollection
+}
+
+func getOAuthTokenCollection() *mongo.Collection {
+	if oauthTokenCollection == nil {
+		oauthTokenCollection = config.GetCollection(config.DB, "oauth_tokens")
+	}
+	return oauthTokenCollection
+}
+
+// oauthCodeTTL is how long a client has to redeem an authorization code
+const oauthCodeTTL = 5 * time.Minute
+
+// OAuthError is an error reported to clients in the RFC 6749 format
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code string, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// RegisterOAuthClient registers a third-party app owned by the user
+func RegisterOAuthClient(ownerID string, input dto.RegisterOAuthClientInput) (*dto.RegisteredOAuthClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, scope := range input.Scopes {
+		if !slices.Contains(models.OAuthScopes, scope) {
+			return nil, errors.New("unknown scope: " + scope)
+		}
+	}
+
+	clientID, err := helpers.GenerateRandomID()
+	if err != nil {
+		return nil, err
+	}
+	client := models.OAuthClient{
+		ID:           primitive.NewObjectID(),
+		ClientID:     clientID,
+		Name:         input.Name,
+		OwnerID:      ownerID,
+		Confidential: input.Confidential,
+		RedirectURIs: input.RedirectURIs,
+		Scopes:       input.Scopes,
+		CreatedAt:    time.Now(),
+	}
+
+	var secret string
+	if client.Confidential {
+		if secret, err = helpers.GenerateRandomID(); err != nil {
+			return nil, err
+		}
+		client.SecretHash = helpers.HashToken(secret)
+	}
+
+	if _, err := getOAuthClientCollection().InsertOne(ctx, client); err != nil {
+		return nil, err
+	}
+	return &dto.RegisteredOAuthClient{ClientSecret: secret, Client: &client}, nil
+}
+
+// GetOAuthClients lists the apps registered by the user
+func GetOAuthClients(ownerID string) ([]models.OAuthClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := getOAuthClientCollection().Find(ctx, bson.M{"owner_id": ownerID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	clients := []models.OAuthClient{}
+	if err = cursor.All(ctx, &clients); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// DeleteOAuthClient removes one of the user's apps along with its consents and tokens
+func DeleteOAuthClient(ownerID string, clientID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := getOAuthClientCollection().DeleteOne(ctx, bson.M{"owner_id": ownerID, "client_id": clientID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("client not found")
+	}
+
+	if _, err := getOAuthConsentCollection().DeleteMany(ctx, bson.M{"client_id": clientID}); err != nil {
+		return err
+	}
+	return revokeOAuthTokens(ctx, bson.M{"client_id": clientID}, "client_deleted")
+}
+
+// authenticateOAuthClient checks the client credentials. Public clients have no secret.
+func authenticateOAuthClient(ctx context.Context, clientID string, secret string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := getOAuthClientCollection().FindOne(ctx, bson.M{"client_id": clientID}).Decode(&client); err != nil {
+		return nil, oauthError("invalid_client", "unknown client")
+	}
+	if client.Confidential && subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(helpers.HashToken(secret))) != 1 {
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	return &client, nil
+}
+
+// parseScopes returns the requested scopes, defaulting to every scope of the client
+func parseScopes(client *models.OAuthClient, scope string) ([]string, error) {
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		return client.Scopes, nil
+	}
+	for _, s := range scopes {
+		if !slices.Contains(client.Scopes, s) {
+			return nil, oauthError("invalid_scope", "scope not allowed for this client: "+s)
+		}
+	}
+	return scopes, nil
+}
+
+// validateAuthorizeRequest checks an authorization request and returns the client and requested scopes
+func validateAuthorizeRequest(ctx context.Context, req dto.AuthorizeRequest) (*models.OAuthClient, []string, error) {
+	var client models.OAuthClient
+	if err := getOAuthClientCollection().FindOne(ctx, bson.M{"client_id": req.ClientID}).Decode(&client); err != nil {
+		return nil, nil, oauthError("invalid_client", "unknown client")
+	}
+	// Never redirect to an unregistered URI, not even with an error
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, nil, oauthError("invalid_request", "redirect_uri is not registered for this client")
+	}
+	if req.ResponseType != "code" {
+		return nil, nil, oauthError("unsupported_response_type", "only the code response type is supported")
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return nil, nil, oauthError("invalid_request", "PKCE with the S256 method is required")
+	}
+
+	scopes, err := parseScopes(&client, req.Scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &client, scopes, nil
+}
+
+// AuthorizeOAuthClient answers an authorization request for the signed-in user. When the user
+// already consented to the scopes the code is issued right away, otherwise consent is required.
+func AuthorizeOAuthClient(userID string, req dto.AuthorizeRequest) (*dto.AuthorizeResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, scopes, err := validateAuthorizeRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var consent models.OAuthConsent
+	err = getOAuthConsentCollection().FindOne(ctx, bson.M{"user_id": userID, "client_id": client.ClientID}).Decode(&consent)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(consent.Scopes, scope) {
+			return &dto.AuthorizeResponse{ConsentRequired: true, ClientName: client.Name, Scopes: scopes}, nil
+		}
+	}
+
+	return issueAuthorizationCode(ctx, userID, client, req, scopes)
+}
+
+// ConsentToOAuthClient records the user's decision and completes the authorization request
+func ConsentToOAuthClient(userID string, input dto.ConsentInput) (*dto.AuthorizeResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, scopes, err := validateAuthorizeRequest(ctx, input.AuthorizeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if !input.Approve {
+		return &dto.AuthorizeResponse{
+			RedirectTo: redirectWithParams(input.RedirectURI, url.Values{"error": {"access_denied"}, "state": {input.State}}),
+		}, nil
+	}
+
+	now := time.Now()
+	_, err = getOAuthConsentCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": userID, "client_id": client.ClientID},
+		bson.M{
+			"$addToSet":    bson.M{"scopes": bson.M{"$each": scopes}},
+			"$set":         bson.M{"updated_at": now},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := auditUserAction(ctx, "user.oauth_consent_granted", userID, userID, map[string]interface{}{"client_id": client.ClientID, "scopes": scopes}); err != nil {
+		return nil, err
+	}
+	return issueAuthorizationCode(ctx, userID, client, input.AuthorizeRequest, scopes)
+}
+
+func issueAuthorizationCode(ctx context.Context, userID string, client *models.OAuthClient, req dto.AuthorizeRequest, scopes []string) (*dto.AuthorizeResponse, error) {
+	code, err := helpers.GenerateRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = getOAuthCodeCollection().InsertOne(ctx, models.OAuthCode{
+		ID:            primitive.NewObjectID(),
+		CodeHash:      helpers.HashToken(code),
+		ClientID:      client.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.AuthorizeResponse{
+		RedirectTo: redirectWithParams(req.RedirectURI, url.Values{"code": {code}, "state": {req.State}}),
+	}, nil
+}
+
+// redirectWithParams appends query parameters to a registered redirect URI, skipping empty values
+func redirectWithParams(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// ExchangeOAuthToken implements the token endpoint for the authorization_code and client_credentials grants
+func ExchangeOAuthToken(req dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := authenticateOAuthClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case "authorization_code":
+		return exchangeAuthorizationCode(ctx, client, req)
+	case "client_credentials":
+		// Only clients that can keep a secret act on their own
+		if !client.Confidential {
+			return nil, oauthError("unauthorized_client", "public clients cannot use client_credentials")
+		}
+		scopes, err := parseScopes(client, req.Scope)
+		if err != nil {
+			return nil, err
+		}
+		// The client acts as the user who registered it, limited to its scopes
+		return issueClientAccessToken(ctx, client, client.OwnerID, scopes, req.GrantType)
+	default:
+		return nil, oauthError("unsupported_grant_type", "grant_type must be authorization_code or client_credentials")
+	}
+}
+
+func exchangeAuthorizationCode(ctx context.Context, client *models.OAuthClient, req dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	// Codes are single use
+	var code models.OAuthCode
+	err := getOAuthCodeCollection().FindOneAndDelete(ctx, bson.M{"code_hash": helpers.HashToken(req.Code)}).Decode(&code)
+	if err != nil {
+		return nil, oauthError("invalid_grant", "invalid authorization code")
+	}
+
+	if code.ClientID != client.ClientID || code.RedirectURI != req.RedirectURI || time.Now().After(code.ExpiresAt) {
+		return nil, oauthError("invalid_grant", "invalid authorization code")
+	}
+	challenge := helpers.PKCEChallenge(req.CodeVerifier)
+	if req.CodeVerifier == "" || subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+		return nil, oauthError("invalid_grant", "code_verifier does not match the code challenge")
+	}
+
+	return issueClientAccessToken(ctx, client, code.UserID, code.Scopes, req.GrantType)
+}
+
+// issueClientAccessToken signs an access token for the client and keeps a record of it
+func issueClientAccessToken(ctx context.Context, client *models.OAuthClient, userID string, scopes []string, grantType string) (*dto.OAuthTokenResponse, error) {
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil || user.Disabled {
+		return nil, oauthError("invalid_grant", "the user is not active")
+	}
+
+	token, jti, expiresAt, err := helpers.GenerateClientAccessToken(user.UserID, user.Role, client.ClientID, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = getOAuthTokenCollection().InsertOne(ctx, models.OAuthToken{
+		ID:        primitive.NewObjectID(),
+		JTI:       jti,
+		ClientID:  client.ClientID,
+		UserID:    user.UserID,
+		Scopes:    scopes,
+		GrantType: grantType,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.OAuthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(expiresAt).Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// findClientToken returns the record of an active token issued to the client, or nil
+func findClientToken(ctx context.Context, client *models.OAuthClient, token string) (*models.OAuthToken, error) {
+	claims, err := helpers.ValidateAccessToken(token)
+	if err != nil {
+		return nil, nil
+	}
+	jti, _ := claims["jti"].(string)
+
+	var record models.OAuthToken
+	err = getOAuthTokenCollection().FindOne(ctx, bson.M{"jti": jti, "client_id": client.ClientID}).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := IsAccessTokenRevoked(jti)
+	if err != nil || revoked {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// IntrospectOAuthToken reports whether a token issued to the calling client is still active.
+// Tokens of other clients and of our own sessions are reported as inactive.
+func IntrospectOAuthToken(clientID string, clientSecret string, token string) (*dto.IntrospectionResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := authenticateOAuthClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := findClientToken(ctx, client, token)
+	if err != nil || record == nil {
+		return &dto.IntrospectionResponse{Active: false}, err
+	}
+
+	disabled, err := IsUserDisabled(record.UserID)
+	if err != nil || disabled {
+		return &dto.IntrospectionResponse{Active: false}, err
+	}
+
+	return &dto.IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(record.Scopes, " "),
+		ClientID:  record.ClientID,
+		Sub:       record.UserID,
+		Exp:       record.ExpiresAt.Unix(),
+		TokenType: "Bearer",
+	}, nil
+}
+
+// RevokeOAuthToken revokes a token issued to the calling client. Unknown tokens are ignored (RFC 7009).
+func RevokeOAuthToken(clientID string, clientSecret string, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := authenticateOAuthClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+
+	record, err := findClientToken(ctx, client, token)
+	if err != nil || record == nil {
+		return err
+	}
+	return revokeAccessToken(ctx, record.JTI, record.UserID, record.ExpiresAt, "oauth_revoked")
+}
+
+// revokeOAuthTokens revokes every unexpired client token matching the filter
+func revokeOAuthTokens(ctx context.Context, filter bson.M, reason string) error {
+	filter["expires_at"] = bson.M{"$gt": time.Now()}
+	cursor, err := getOAuthTokenCollection().Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var tokens []models.OAuthToken
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := revokeAccessToken(ctx, token.JTI, token.UserID, token.ExpiresAt, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOAuthConsents lists the apps the user has granted access to
+func GetOAuthConsents(userID string) ([]models.OAuthConsent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := getOAuthConsentCollection().Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	consents := []models.OAuthConsent{}
+	if err = cursor.All(ctx, &consents); err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// RevokeOAuthConsent withdraws the user's consent and revokes the tokens the app holds for them
+func RevokeOAuthConsent(userID string, clientID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := getOAuthConsentCollection().DeleteOne(ctx, bson.M{"user_id": userID, "client_id": clientID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("consent not found")
+	}
+
+	if err := revokeOAuthTokens(ctx, bson.M{"user_id": userID, "client_id": clientID, "grant_type": "authorization_code"}, "consent_revoked"); err != nil {
+		return err
+	}
+	return auditUserAction(ctx, "user.oauth_consent_revoked", userID, userID, map[string]interface{}{"client_id": clientID})
+}
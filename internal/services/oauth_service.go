@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+	"github.com/alpha-154/crud-go-gin/internal/oauth"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var (
+	oauthStateCollection       *mongo.Collection
+	externalIdentityCollection *mongo.Collection
+
+	oauthProviders     map[string]*oauth.Provider
+	oauthProvidersOnce sync.Once
+)
+
+func getOAuthStateCollection() *mongo.Collection {
+	if oauthStateCollection == nil {
+		oauthStateCollection = config.GetCollection(config.DB, "oauth_states")
+	}
+	return oauthStateCollection
+}
+
+func getExternalIdentityCollection() *mongo.Collection {
+	if externalIdentityCollection == nil {
+		externalIdentityCollection = config.GetCollection(config.DB, "external_identities")
+	}
+	return externalIdentityCollection
+}
+
+// SetOAuthProviders replaces the social login providers, e.g. in tests.
+func SetOAuthProviders(providers map[string]*oauth.Provider) {
+	oauthProvidersOnce.Do(func() {})
+	oauthProviders = providers
+}
+
+// getOAuthProviders loads the social login providers from the providers file on first use
+func getOAuthProviders() map[string]*oauth.Provider {
+	oauthProvidersOnce.Do(func() {
+		providers, err := oauth.LoadProvidersFromEnv()
+		if err != nil {
+			log.Println("Failed to load OAuth providers:", err)
+			providers = map[string]*oauth.Provider{}
+		}
+		oauthProviders = providers
+	})
+	return oauthProviders
+}
+
+// OAuthStateTTL is how long the user has to complete the login at the provider
+const OAuthStateTTL = 10 * time.Minute
+
+var errUnknownProvider = errors.New("unknown provider")
+
+// GetOAuthProviders returns the names of the configured social login providers
+func GetOAuthProviders() []string {
+	names := []string{}
+	for name := range getOAuthProviders() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOAuthSignIn returns the provider URL that starts the authorization code flow, and its state.
+// The caller must bind the state to the browser, see controllers.StartOAuth.
+func StartOAuthSignIn(providerName string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	provider, ok := getOAuthProviders()[providerName]
+	if !ok {
+		return "", "", errUnknownProvider
+	}
+
+	state, err := helpers.GenerateRandomID()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := helpers.GeneratePKCEVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, helpers.PKCEChallenge(verifier))
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	_, err = getOAuthStateCollection().InsertOne(ctx, models.OAuthState{
+		ID:           primitive.NewObjectID(),
+		StateHash:    helpers.HashToken(state),
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(OAuthStateTTL),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// CompleteOAuthSignIn handles the provider redirect: it checks the state, exchanges the code,
+// finds or links the user and starts a session like a password sign-in.
+func CompleteOAuthSignIn(providerName string, code string, state string, client dto.ClientInfo) (*dto.SignInServiceResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	provider, ok := getOAuthProviders()[providerName]
+	if !ok {
+		return nil, errUnknownProvider
+	}
+
+	// The state is single use
+	var pending models.OAuthState
+	err := getOAuthStateCollection().FindOneAndDelete(ctx, bson.M{
+		"state_hash": helpers.HashToken(state),
+		"provider":   providerName,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&pending)
+	if err != nil {
+		return nil, errors.New("invalid or expired state")
+	}
+
+	accessToken, err := provider.Exchange(ctx, code, pending.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := provider.FetchIdentity(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := findOrLinkOAuthUser(ctx, providerName, identity)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, errors.New("account disabled")
+	}
+
+	if user.MFAEnabled {
+		mfaToken, err := createMFAChallenge(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.SignInServiceResponse{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+
+	client.Device = providerName
+	tokens, err := startSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.SignInServiceResponse{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+// findOrLinkOAuthUser returns the user linked to the external identity. Unknown identities are
+// linked to the user with the same verified email, or to a newly created user.
+func findOrLinkOAuthUser(ctx context.Context, providerName string, identity *oauth.Identity) (*models.User, error) {
+	now := time.Now()
+
+	var link models.ExternalIdentity
+	err := getExternalIdentityCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"provider": providerName, "subject": identity.Subject},
+		bson.M{"$set": bson.M{"last_login_at": now}},
+	).Decode(&link)
+	if err == nil {
+		return GetUserByID(link.UserID)
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, errors.New("the provider did not return a verified email")
+	}
+
+	var user models.User
+	err = getUserCollection().FindOne(ctx, bson.M{"email": identity.Email}).Decode(&user)
+	switch {
+	case err == mongo.ErrNoDocuments:
+		// Social login users get a random password they can replace through a reset
+		password, err := helpers.GenerateRandomID()
+		if err != nil {
+			return nil, err
+		}
+		created, err := createUser(ctx, identity.Name, identity.Email, password, models.RoleUser)
+		if err != nil {
+			return nil, err
+		}
+		_, err = getUserCollection().UpdateOne(
+			ctx,
+			bson.M{"user_id": created.UserID},
+			bson.M{"$set": bson.M{"email_verified": true, "email_verified_at": now}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		created.EmailVerified = true
+		created.EmailVerifiedAt = &now
+		user = *created
+	case err != nil:
+		return nil, err
+	case !user.EmailVerified:
+		// Someone else may have registered the address, don't hand them the account
+		return nil, errors.New("an account with this email exists but is not verified, sign in with your password first")
+	}
+
+	_, err = getExternalIdentityCollection().InsertOne(ctx, models.ExternalIdentity{
+		ID:          primitive.NewObjectID(),
+		Provider:    providerName,
+		Subject:     identity.Subject,
+		UserID:      user.UserID,
+		Email:       identity.Email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = auditUserAction(ctx, "user.identity_linked", user.UserID, user.UserID, map[string]interface{}{"provider": providerName})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+	"github.com/alpha-154/crud-go-gin/internal/passwords"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	passwordPolicy     *passwords.Policy
+	passwordPolicyOnce sync.Once
+)
+
+// getPasswordPolicy loads the password policy from the environment on first use
+func getPasswordPolicy() *passwords.Policy {
+	passwordPolicyOnce.Do(func() {
+		policy, err := passwords.PolicyFromEnv()
+		if err != nil {
+			log.Fatal("Invalid password policy:", err)
+		}
+		passwordPolicy = policy
+	})
+	return passwordPolicy
+}
+
+// LoadPasswordPolicy loads the policy right away, so configuration errors stop the server on startup
+func LoadPasswordPolicy() {
+	getPasswordPolicy()
+}
+
+// validateNewPassword checks a password for a new account against the policy
+func validateNewPassword(password string, email string, name string) error {
+	return getPasswordPolicy().Check(password, []string{email, name}, nil)
+}
+
+// setUserPassword checks the new password against the policy and the user's previous
+// passwords, then stores it and remembers the old hash.
+func setUserPassword(ctx context.Context, userID string, password string) error {
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil {
+		return errors.New("user not found")
+	}
+
+	policy := getPasswordPolicy()
+	previous := append([]string{user.Password}, user.PasswordHistory...)
+	if err := policy.Check(password, []string{user.Email, user.Name}, previous); err != nil {
+		return err
+	}
+
+	hashedPassword, err := helpers.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// Together with the new password, keep the last policy.History ones
+	if keep := max(policy.History-1, 0); len(previous) > keep {
+		previous = previous[:keep]
+	}
+	_, err = getUserCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"password": hashedPassword, "password_history": previous, "updated_at": time.Now()}},
+	)
+	return err
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/mailer"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const passwordResetTTL = time.Hour
+
+// RequestPasswordReset emails a password reset link to the user in the background.
+// Unknown emails are ignored, and the caller never waits for the lookup or the mail,
+// so neither the answer nor its timing reveals which accounts exist.
+func RequestPasswordReset(email string) {
+	go func() {
+		if err := sendPasswordReset(email); err != nil {
+			log.Println("Failed to send password reset email:", err)
+		}
+	}()
+}
+
+func sendPasswordReset(email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := getUserCollection().FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		log.Println("Password reset requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := createUserToken(ctx, user.UserID, models.TokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return sendMail(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in one hour.\n\n%s\n\n"+
+			"If you did not ask for a password reset you can ignore this email.\n",
+			user.Name, appLink("/reset-password", token)),
+	})
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere.
+func ResetPassword(token string, newPassword string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	record, err := consumeUserToken(ctx, token, models.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	if err := setUserPassword(ctx, record.UserID, newPassword); err != nil {
+		return err
+	}
+
+	// Whoever had access before the reset must sign in again
+	return revokeUserSessions(ctx, record.UserID, "password_reset")
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/mailer"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const emailChangeTTL = 24 * time.Hour
+
+// checkCurrentPassword loads the user and verifies their current password.
+func checkCurrentPassword(ctx context.Context, userID string, password string) (*models.User, error) {
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil {
+		return nil, errors.New("user not found")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, errors.New("current password is incorrect")
+	}
+	return &user, nil
+}
+
+// UpdateProfile changes the user's own name
+func UpdateProfile(userID string, name string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := getUserCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"name": name, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	return &user, nil
+}
+
+// ChangePassword sets a new password after checking the current one.
+// Every other session of the user is signed out; the current one stays.
+func ChangePassword(userID string, currentSessionID string, currentPassword string, newPassword string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := checkCurrentPassword(ctx, userID, currentPassword); err != nil {
+		return err
+	}
+
+	if err := setUserPassword(ctx, userID, newPassword); err != nil {
+		return err
+	}
+
+	return revokeOtherSessions(ctx, userID, currentSessionID, "password_changed")
+}
+
+// RequestEmailChange sends a confirmation link to the new address.
+// The email only changes once the link is opened.
+func RequestEmailChange(userID string, newEmail string, currentPassword string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := checkCurrentPassword(ctx, userID, currentPassword)
+	if err != nil {
+		return err
+	}
+	if newEmail == user.Email {
+		return errors.New("this is already your email")
+	}
+
+	emailTaken, err := helpers.IsEmailTaken(ctx, newEmail)
+	if err != nil {
+		return fmt.Errorf("error checking email: %w", err)
+	}
+	if emailTaken {
+		return errors.New("email already exists")
+	}
+
+	_, err = getUserCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"pending_email": newEmail, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+
+	token, err := createUserToken(ctx, userID, models.TokenPurposeEmailChange, emailChangeTTL)
+	if err != nil {
+		return err
+	}
+
+	return sendMail(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to use this address for your account. It expires in 24 hours.\n\n%s\n",
+			user.Name, appLink("/confirm-email", token)),
+	})
+}
+
+// ConfirmEmailChange replaces the user's email with the pending one.
+func ConfirmEmailChange(token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	record, err := consumeUserToken(ctx, token, models.TokenPurposeEmailChange)
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": record.UserID}).Decode(&user); err != nil {
+		return errors.New("user not found")
+	}
+	if user.PendingEmail == "" {
+		return errors.New("no email change pending")
+	}
+
+	// Someone may have registered the address in the meantime
+	var other models.User
+	err = getUserCollection().FindOne(ctx, bson.M{"email": user.PendingEmail}).Decode(&other)
+	if err == nil {
+		return errors.New("email already exists")
+	}
+	if err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	now := time.Now()
+	_, err = getUserCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": user.UserID},
+		bson.M{
+			"$set":   bson.M{"email": user.PendingEmail, "email_verified": true, "email_verified_at": now, "updated_at": now},
+			"$unset": bson.M{"pending_email": ""},
+		},
+	)
+	return err
+}
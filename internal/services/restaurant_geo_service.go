@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var ErrInvalidLocation = errors.New("location must be a GeoJSON point with [longitude, latitude] coordinates")
+
+// earthRadiusMeters is the mean radius used for distances
+const earthRadiusMeters = 6371008.8
+
+// distancedRestaurant is a restaurant decoded together with its distance from $geoNear
+type distancedRestaurant struct {
+	models.Restaurant `bson:",inline"`
+	Distance          float64 `bson:"distance"`
+}
+
+// FindRestaurantsNear returns the restaurants closest to the point, optionally within a maximum distance
+func FindRestaurantsNear(query dto.NearbyRestaurantsQuery) ([]dto.GeoRestaurantResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	geoNear := bson.M{
+		"near":          models.NewGeoPoint(*query.Lng, *query.Lat),
+		"distanceField": "distance",
+		"spherical":     true,
+		"key":           "location",
+	}
+	if query.MaxDistance > 0 {
+		geoNear["maxDistance"] = query.MaxDistance
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$geoNear", Value: geoNear}},
+		{{Key: "$limit", Value: pageSize(query.Limit)}},
+	}
+	cursor, err := getRestaurantCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var matches []distancedRestaurant
+	if err := cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+
+	results := []dto.GeoRestaurantResult{}
+	for _, match := range matches {
+		distance := match.Distance
+		results = append(results, dto.GeoRestaurantResult{Restaurant: match.Restaurant, DistanceMeters: &distance})
+	}
+	return results, nil
+}
+
+// FindRestaurantsWithin returns the restaurants inside a bounding box or polygon
+func FindRestaurantsWithin(query dto.RestaurantsWithinQuery) ([]dto.GeoRestaurantResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var ring [][]float64
+	var err error
+	switch {
+	case query.BBox != "" && query.Polygon != "":
+		return nil, fmt.Errorf("%w: use either bbox or polygon", ErrInvalidQuery)
+	case query.BBox != "":
+		ring, err = parseBBox(query.BBox)
+	case query.Polygon != "":
+		ring, err = parsePolygon(query.Polygon)
+	default:
+		return nil, fmt.Errorf("%w: bbox or polygon is required", ErrInvalidQuery)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if (query.Lat == nil) != (query.Lng == nil) {
+		return nil, fmt.Errorf("%w: lat and lng must be given together", ErrInvalidQuery)
+	}
+
+	filter := bson.M{"location": bson.M{"$geoWithin": bson.M{"$geometry": bson.M{
+		"type":        "Polygon",
+		"coordinates": [][][]float64{ring},
+	}}}}
+	cursor, err := getRestaurantCollection().Find(ctx, filter, options.Find().SetLimit(int64(pageSize(query.Limit))))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var restaurants []models.Restaurant
+	if err := cursor.All(ctx, &restaurants); err != nil {
+		return nil, err
+	}
+
+	results := []dto.GeoRestaurantResult{}
+	for _, restaurant := range restaurants {
+		result := dto.GeoRestaurantResult{Restaurant: restaurant}
+		if query.Lat != nil && restaurant.Location != nil {
+			distance := haversineMeters(*query.Lat, *query.Lng, restaurant.Location.Coordinates[1], restaurant.Location.Coordinates[0])
+			result.DistanceMeters = &distance
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// parseBBox turns "minLng,minLat,maxLng,maxLat" into a closed polygon ring
+func parseBBox(bbox string) ([][]float64, error) {
+	values, err := parseCoordinates(strings.Split(bbox, ","))
+	if err != nil || len(values) != 4 {
+		return nil, fmt.Errorf("%w: bbox must be minLng,minLat,maxLng,maxLat", ErrInvalidQuery)
+	}
+	minLng, minLat, maxLng, maxLat := values[0], values[1], values[2], values[3]
+	if minLng >= maxLng || minLat >= maxLat || !validLngLat(minLng, minLat) || !validLngLat(maxLng, maxLat) {
+		return nil, fmt.Errorf("%w: bbox corners are out of range or in the wrong order", ErrInvalidQuery)
+	}
+	return [][]float64{{minLng, minLat}, {maxLng, minLat}, {maxLng, maxLat}, {minLng, maxLat}, {minLng, minLat}}, nil
+}
+
+// parsePolygon turns "lng,lat;lng,lat;..." into a closed polygon ring
+func parsePolygon(polygon string) ([][]float64, error) {
+	ring := [][]float64{}
+	for _, pair := range strings.Split(polygon, ";") {
+		values, err := parseCoordinates(strings.Split(pair, ","))
+		if err != nil || len(values) != 2 || !validLngLat(values[0], values[1]) {
+			return nil, fmt.Errorf("%w: polygon must be lng,lat pairs separated by ;", ErrInvalidQuery)
+		}
+		ring = append(ring, values)
+	}
+
+	first, last := ring[0], ring[len(ring)-1]
+	if first[0] != last[0] || first[1] != last[1] {
+		ring = append(ring, first)
+	}
+	if len(ring) < 4 {
+		return nil, fmt.Errorf("%w: polygon needs at least 3 points", ErrInvalidQuery)
+	}
+	return ring, nil
+}
+
+func parseCoordinates(parts []string) ([]float64, error) {
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func validLngLat(lng float64, lat float64) bool {
+	return models.NewGeoPoint(lng, lat).Valid()
+}
+
+// haversineMeters returns the great-circle distance between two points
+func haversineMeters(lat1 float64, lng1 float64, lat2 float64, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
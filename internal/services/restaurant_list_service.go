@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	bsonv2 "go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ErrInvalidQuery is returned for unusable filters, sorts or cursors
+var ErrInvalidQuery = errors.New("invalid query")
+
+const (
+	defaultRestaurantPageSize = 20
+	maxRestaurantPageSize     = 100
+)
+
+// restaurantSortFields are the fields restaurants can be sorted by
+var restaurantSortFields = []string{"name", "cuisine", "address", "email"}
+
+// pageSize applies the default and maximum page size to a requested limit
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultRestaurantPageSize
+	}
+	return min(limit, maxRestaurantPageSize)
+}
+
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// restaurantCursor is the position after the last restaurant of a page.
+// restaurant_id breaks ties so every position is unique.
+type restaurantCursor struct {
+	Sort         string   `json:"s"`
+	Values       []string `json:"v"`
+	RestaurantID string   `json:"id"`
+}
+
+// parseRestaurantSort parses a sort like "cuisine,-name"; a leading "-" sorts descending
+func parseRestaurantSort(sort string) ([]sortKey, error) {
+	keys := []sortKey{}
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key := sortKey{field: strings.TrimPrefix(part, "-"), desc: strings.HasPrefix(part, "-")}
+		if !slices.Contains(restaurantSortFields, key.field) {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, key.field)
+		}
+		if slices.ContainsFunc(keys, func(k sortKey) bool { return k.field == key.field }) {
+			return nil, fmt.Errorf("%w: %q is sorted by twice", ErrInvalidQuery, key.field)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// sortSpec is the canonical form of the sort, stored in cursors
+func sortSpec(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.field
+		if key.desc {
+			parts[i] = "-" + key.field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeRestaurantCursor(keys []sortKey, restaurant models.Restaurant) string {
+	fields := map[string]string{
+		"name":    restaurant.Name,
+		"cuisine": restaurant.Cuisine,
+		"address": restaurant.Address,
+		"email":   restaurant.Email,
+	}
+	cursor := restaurantCursor{Sort: sortSpec(keys), RestaurantID: restaurant.RestaurantID}
+	for _, key := range keys {
+		cursor.Values = append(cursor.Values, fields[key.field])
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeRestaurantCursor(keys []sortKey, encoded string) (*restaurantCursor, error) {
+	invalid := fmt.Errorf("%w: invalid cursor", ErrInvalidQuery)
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid
+	}
+	var cursor restaurantCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.RestaurantID == "" {
+		return nil, invalid
+	}
+	// A cursor only makes sense with the sort it was created for
+	if cursor.Sort != sortSpec(keys) || len(cursor.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: the cursor belongs to a different sort", ErrInvalidQuery)
+	}
+	return &cursor, nil
+}
+
+// keysetFilter matches the restaurants that come after the cursor in the sort order
+func keysetFilter(keys []sortKey, cursor *restaurantCursor) bson.M {
+	or := []bson.M{}
+	for i := 0; i <= len(keys); i++ {
+		clause := bson.M{}
+		for j := 0; j < i; j++ {
+			clause[keys[j].field] = cursor.Values[j]
+		}
+		if i < len(keys) {
+			op := "$gt"
+			if keys[i].desc {
+				op = "$lt"
+			}
+			clause[keys[i].field] = bson.M{op: cursor.Values[i]}
+		} else {
+			clause["restaurant_id"] = bson.M{"$gt": cursor.RestaurantID}
+		}
+		or = append(or, clause)
+	}
+	return bson.M{"$or": or}
+}
+
+// restaurantFilter builds the filter for the cuisine, name and address query parameters
+func restaurantFilter(query dto.RestaurantListQuery) bson.M {
+	filter := bson.M{}
+	if query.Cuisine != "" {
+		cuisines := []string{}
+		for _, cuisine := range strings.Split(query.Cuisine, ",") {
+			if cuisine = normalizeCuisine(cuisine); cuisine != "" {
+				cuisines = append(cuisines, cuisine)
+			}
+		}
+		filter["cuisine"] = bson.M{"$in": cuisines}
+	}
+	if query.Name != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(query.Name), "$options": "i"}
+	}
+	if query.Address != "" {
+		filter["address"] = bson.M{"$regex": regexp.QuoteMeta(query.Address), "$options": "i"}
+	}
+	return filter
+}
+
+// GetAllRestaurants returns one page of restaurants matching the query
+func GetAllRestaurants(query dto.RestaurantListQuery) (*dto.RestaurantPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if query.Cursor != "" && query.Offset > 0 {
+		return nil, fmt.Errorf("%w: use either cursor or offset", ErrInvalidQuery)
+	}
+	limit := pageSize(query.Limit)
+
+	keys, err := parseRestaurantSort(query.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := restaurantFilter(query)
+	page := &dto.RestaurantPage{Limit: limit, Offset: query.Offset}
+
+	if query.IncludeTotal {
+		total, err := getRestaurantCollection().CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeRestaurantCursor(keys, query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter = bson.M{"$and": []bson.M{filter, keysetFilter(keys, cursor)}}
+	}
+
+	// The sort document must keep its order
+	sort := bsonv2.D{}
+	for _, key := range keys {
+		direction := 1
+		if key.desc {
+			direction = -1
+		}
+		sort = append(sort, bsonv2.E{Key: key.field, Value: direction})
+	}
+	sort = append(sort, bsonv2.E{Key: "restaurant_id", Value: 1})
+
+	// Fetch one extra restaurant to know whether there is a next page
+	opts := options.Find().SetSort(sort).SetLimit(int64(limit + 1))
+	if query.Offset > 0 {
+		opts.SetSkip(int64(query.Offset))
+	}
+
+	cursor, err := getRestaurantCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	restaurants := []models.Restaurant{}
+	if err = cursor.All(ctx, &restaurants); err != nil {
+		return nil, err
+	}
+
+	if len(restaurants) > limit {
+		restaurants = restaurants[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeRestaurantCursor(keys, restaurants[limit-1])
+	}
+	page.Data = restaurants
+	return page, nil
+}
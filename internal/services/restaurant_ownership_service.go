@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var (
+	ErrRestaurantNotFound = errors.New("restaurant not found")
+	ErrForbidden          = errors.New("you are not allowed to modify this restaurant")
+)
+
+// unowned matches the owner_id of restaurants stored before ownership existed, where it is missing or empty.
+var unowned = bson.M{"$in": bson.A{nil, ""}}
+
+// isRestaurantAdmin reports whether the actor may modify restaurants of any owner.
+func isRestaurantAdmin(actor dto.Actor) bool {
+	return models.HasPermission(actor.Permissions, models.PermissionRestaurantsAdmin)
+}
+
+// canManageRestaurant reports whether the actor is the owner, a manager or a restaurant admin.
+func canManageRestaurant(restaurant *models.Restaurant, actor dto.Actor) bool {
+	if isRestaurantAdmin(actor) {
+		return true
+	}
+	return actor.UserID != "" &&
+		(restaurant.OwnerID == actor.UserID || slices.Contains(restaurant.ManagerIDs, actor.UserID))
+}
+
+// canAdministerRestaurant reports whether the actor is the owner or a restaurant admin.
+// Managers cannot change who manages or owns the restaurant.
+func canAdministerRestaurant(restaurant *models.Restaurant, actor dto.Actor) bool {
+	return isRestaurantAdmin(actor) || (actor.UserID != "" && restaurant.OwnerID == actor.UserID)
+}
+
+func loadRestaurant(ctx context.Context, id string) (*models.Restaurant, error) {
+	var restaurant models.Restaurant
+	err := getRestaurantCollection().FindOne(ctx, bson.M{"restaurant_id": id}).Decode(&restaurant)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRestaurantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &restaurant, nil
+}
+
+// loadManageableRestaurant loads the restaurant if the actor may modify it.
+func loadManageableRestaurant(ctx context.Context, id string, actor dto.Actor) (*models.Restaurant, error) {
+	restaurant, err := loadRestaurant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canManageRestaurant(restaurant, actor) {
+		return nil, ErrForbidden
+	}
+	return restaurant, nil
+}
+
+// GetRestaurantsForUser lists the restaurants the user owns or manages
+func GetRestaurantsForUser(userID string) ([]models.Restaurant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := getRestaurantCollection().Find(ctx, bson.M{"$or": []bson.M{
+		{"owner_id": userID},
+		{"manager_ids": userID},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	restaurants := []models.Restaurant{}
+	if err = cursor.All(ctx, &restaurants); err != nil {
+		return nil, err
+	}
+	return restaurants, nil
+}
+
+// AddRestaurantManager lets another user manage the restaurant
+func AddRestaurantManager(id string, managerID string, actor dto.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	restaurant, err := loadRestaurant(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !canAdministerRestaurant(restaurant, actor) {
+		return ErrForbidden
+	}
+	if managerID == restaurant.OwnerID {
+		return errors.New("the owner cannot also be a manager")
+	}
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": managerID}).Err(); err != nil {
+		return errors.New("user not found")
+	}
+
+	_, err = getRestaurantCollection().UpdateOne(
+		ctx,
+		bson.M{"restaurant_id": id},
+		bson.M{"$addToSet": bson.M{"manager_ids": managerID}},
+	)
+	if err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, models.AuditLog{
+		Action:     "restaurant.manager_added",
+		ActorID:    actor.UserID,
+		TargetType: "restaurant",
+		TargetID:   id,
+		Details:    map[string]interface{}{"manager_id": managerID},
+	})
+}
+
+// RemoveRestaurantManager revokes a user's right to manage the restaurant
+func RemoveRestaurantManager(id string, managerID string, actor dto.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	restaurant, err := loadRestaurant(ctx, id)
+	if err != nil {
+		return err
+	}
+	// Managers may step down themselves
+	if !canAdministerRestaurant(restaurant, actor) && managerID != actor.UserID {
+		return ErrForbidden
+	}
+
+	_, err = getRestaurantCollection().UpdateOne(
+		ctx,
+		bson.M{"restaurant_id": id},
+		bson.M{"$pull": bson.M{"manager_ids": managerID}},
+	)
+	if err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, models.AuditLog{
+		Action:     "restaurant.manager_removed",
+		ActorID:    actor.UserID,
+		TargetType: "restaurant",
+		TargetID:   id,
+		Details:    map[string]interface{}{"manager_id": managerID},
+	})
+}
+
+// TransferRestaurantOwnership hands the restaurant over to another user and records it in the audit log
+func TransferRestaurantOwnership(id string, newOwnerID string, actor dto.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	restaurant, err := loadRestaurant(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !canAdministerRestaurant(restaurant, actor) {
+		return ErrForbidden
+	}
+	if newOwnerID == restaurant.OwnerID {
+		return errors.New("user already owns the restaurant")
+	}
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": newOwnerID}).Err(); err != nil {
+		return errors.New("user not found")
+	}
+
+	// Only transfer if nobody changed the owner in the meantime
+	currentOwner := interface{}(restaurant.OwnerID)
+	if restaurant.OwnerID == "" {
+		currentOwner = unowned
+	}
+	result, err := getRestaurantCollection().UpdateOne(
+		ctx,
+		bson.M{"restaurant_id": id, "owner_id": currentOwner},
+		bson.M{
+			"$set":  bson.M{"owner_id": newOwnerID},
+			"$pull": bson.M{"manager_ids": newOwnerID},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("restaurant ownership changed concurrently, please retry")
+	}
+
+	return recordAudit(ctx, models.AuditLog{
+		Action:     "restaurant.ownership_transferred",
+		ActorID:    actor.UserID,
+		TargetType: "restaurant",
+		TargetID:   id,
+		Details: map[string]interface{}{
+			"previous_owner_id": restaurant.OwnerID,
+			"new_owner_id":      newOwnerID,
+		},
+	})
+}
+
+// BackfillRestaurantOwners gives restaurants stored before ownership existed to the user whose
+// email is in RESTAURANT_BACKFILL_OWNER. Without it they are only logged: until someone with
+// restaurants:admin assigns an owner through POST /restaurants/:id/transfer, nobody but
+// restaurant admins can modify them. It is safe to call on every startup.
+func BackfillRestaurantOwners() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	email := os.Getenv("RESTAURANT_BACKFILL_OWNER")
+	if email == "" {
+		count, err := getRestaurantCollection().CountDocuments(ctx, bson.M{"owner_id": unowned})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			log.Println(count, "restaurants have no owner; set RESTAURANT_BACKFILL_OWNER or transfer them to assign one")
+		}
+		return nil
+	}
+
+	var owner models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"email": email}).Decode(&owner); err != nil {
+		return fmt.Errorf("RESTAURANT_BACKFILL_OWNER: user %s not found", email)
+	}
+
+	result, err := getRestaurantCollection().UpdateMany(
+		ctx,
+		bson.M{"owner_id": unowned},
+		bson.M{"$set": bson.M{"owner_id": owner.UserID}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return nil
+	}
+	log.Println("Assigned", result.ModifiedCount, "restaurants without an owner to", owner.Email)
+
+	return recordAudit(ctx, models.AuditLog{
+		Action:     "restaurant.owners_backfilled",
+		TargetType: "user",
+		TargetID:   owner.UserID,
+		Details:    map[string]interface{}{"restaurants": result.ModifiedCount},
+	})
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	bsonv2 "go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// fuzzyMatchThreshold is the share of the query's trigrams a restaurant must contain
+// to be returned by the fallback search
+const fuzzyMatchThreshold = 0.5
+
+// scoredRestaurant is a restaurant decoded together with its search score
+type scoredRestaurant struct {
+	models.Restaurant `bson:",inline"`
+	Score             float64 `bson:"score"`
+}
+
+// SearchRestaurants runs a full-text search ranked by relevance. The query supports
+// "quoted phrases" and -negated words. When the text index finds nothing, restaurants
+// sharing enough trigrams with the query are returned instead, which tolerates typos.
+func SearchRestaurants(query dto.RestaurantSearchQuery) (*dto.RestaurantSearchResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	limit := pageSize(query.Limit)
+
+	response := &dto.RestaurantSearchResponse{Query: query.Q, Mode: "text"}
+	matches, err := textSearchRestaurants(ctx, query.Q, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		response.Mode = "fuzzy"
+		if matches, err = fuzzySearchRestaurants(ctx, query.Q, limit); err != nil {
+			return nil, err
+		}
+	}
+
+	terms := helpers.SearchTerms(query.Q)
+	response.Results = []dto.RestaurantSearchResult{}
+	for _, match := range matches {
+		result := dto.RestaurantSearchResult{Restaurant: match.Restaurant, Score: match.Score, Highlights: map[string]string{}}
+		fields := map[string]string{"name": match.Name, "cuisine": match.Cuisine, "address": match.Address}
+		for field, text := range fields {
+			if snippet, ok := helpers.Highlight(text, terms); ok {
+				result.Highlights[field] = snippet
+			}
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response, nil
+}
+
+func textSearchRestaurants(ctx context.Context, q string, limit int) ([]scoredRestaurant, error) {
+	score := bsonv2.D{{Key: "score", Value: bsonv2.D{{Key: "$meta", Value: "textScore"}}}}
+	opts := options.Find().
+		SetProjection(score).
+		SetSort(score).
+		SetLimit(int64(limit))
+
+	cursor, err := getRestaurantCollection().Find(ctx, bson.M{"$text": bson.M{"$search": q}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	matches := []scoredRestaurant{}
+	if err := cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// fuzzySearchRestaurants ranks restaurants by the share of the query's trigrams they contain
+func fuzzySearchRestaurants(ctx context.Context, q string, limit int) ([]scoredRestaurant, error) {
+	grams := helpers.SearchTrigrams(helpers.SearchTerms(q)...)
+	if len(grams) == 0 {
+		return []scoredRestaurant{}, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"search_grams": bson.M{"$in": grams}}}},
+		{{Key: "$addFields", Value: bson.M{"score": bson.M{"$divide": bson.A{
+			bson.M{"$size": bson.M{"$setIntersection": bson.A{"$search_grams", grams}}},
+			len(grams),
+		}}}}},
+		{{Key: "$match", Value: bson.M{"score": bson.M{"$gte": fuzzyMatchThreshold}}}},
+		{{Key: "$sort", Value: bsonv2.D{{Key: "score", Value: -1}, {Key: "restaurant_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := getRestaurantCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	matches := []scoredRestaurant{}
+	if err := cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// BackfillRestaurantSearchGrams computes the search trigrams of restaurants stored before
+// typo-tolerant search existed. It is safe to call on every startup.
+func BackfillRestaurantSearchGrams() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cursor, err := getRestaurantCollection().Find(ctx, bson.M{"search_grams": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	updated := 0
+	for cursor.Next(ctx) {
+		var restaurant models.Restaurant
+		if err := cursor.Decode(&restaurant); err != nil {
+			return err
+		}
+		_, err := getRestaurantCollection().UpdateOne(
+			ctx,
+			bson.M{"restaurant_id": restaurant.RestaurantID},
+			bson.M{"$set": bson.M{"search_grams": helpers.SearchTrigrams(restaurant.Name, restaurant.Cuisine, restaurant.Address)}},
+		)
+		if err != nil {
+			return err
+		}
+		updated++
+	}
+	if updated > 0 {
+		log.Println("Computed search trigrams for", updated, "restaurants")
+	}
+	return cursor.Err()
+}
@@ -2,16 +2,19 @@ package services
 
 import (
 	"context"
-	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
 	"github.com/alpha-154/crud-go-gin/internal/models"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 var restaurantCollection *mongo.Collection
@@ -28,16 +31,16 @@ func getRestaurantCollection() *mongo.Collection {
 	return restaurantCollection
 }
 
-func CreateRestaurant(restaurant models.Restaurant) (*mongo.InsertOneResult, error) {
+// CreateRestaurant stores a new restaurant owned by ownerID
+func CreateRestaurant(restaurant models.Restaurant, ownerID string) (*mongo.InsertOneResult, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Generate a new MongoDB ObjectID
-	restaurant.ID = primitive.NewObjectID()
-	fmt.Println("Generated ObjectID:", restaurant.ID)
+	if restaurant.Location != nil && !restaurant.Location.Valid() {
+		return nil, ErrInvalidLocation
+	}
 
-	// Convert ObjectID to a string and store it in RestaurantID
-	restaurant.RestaurantID = restaurant.ID.Hex()
+	restaurant = newRestaurant(restaurant, ownerID)
 
 	// Insert restaurant into MongoDB
 	result, err := getRestaurantCollection().InsertOne(ctx, restaurant)
@@ -45,39 +48,68 @@ func CreateRestaurant(restaurant models.Restaurant) (*mongo.InsertOneResult, err
 		return nil, err
 	}
 
+	if restaurant.GeocodeStatus == models.GeocodePending {
+		enqueueGeocode(restaurant.RestaurantID, restaurant.Address, 1)
+	}
 	return result, nil
 }
 
-func GetAllRestaurants() ([]map[string]interface{}, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// newRestaurant fills in the identifiers, ownership and derived fields of a restaurant about to be stored
+func newRestaurant(restaurant models.Restaurant, ownerID string) models.Restaurant {
+	// Generate a new MongoDB ObjectID
+	restaurant.ID = primitive.NewObjectID()
 
-	cursor, err := getRestaurantCollection().Find(ctx, bson.M{}) // Fetch all documents
-	if err != nil {
-		return nil, err
+	// Convert ObjectID to a string and store it in RestaurantID
+	restaurant.RestaurantID = restaurant.ID.Hex()
+
+	// The creator owns the restaurant
+	restaurant.OwnerID = ownerID
+	restaurant.ManagerIDs = []string{}
+	restaurant.Cuisine = normalizeCuisine(restaurant.Cuisine)
+	restaurant.SearchGrams = helpers.SearchTrigrams(restaurant.Name, restaurant.Cuisine, restaurant.Address)
+
+	// A location sent by the client wins, otherwise it is looked up from the address
+	restaurant.GeocodeStatus, restaurant.GeocodeError, restaurant.GeocodedAt = "", "", nil
+	if restaurant.Location != nil {
+		restaurant.GeocodeStatus = models.GeocodeManual
+	} else if restaurant.Address != "" && geocodingEnabled() {
+		restaurant.GeocodeStatus = models.GeocodePending
 	}
-	defer cursor.Close(ctx)
+	return restaurant
+}
 
-	var response []map[string]interface{}
+// normalizeCuisine stores cuisines in lower case, the way they are validated and filtered
+func normalizeCuisine(cuisine string) string {
+	return strings.ToLower(strings.TrimSpace(cuisine))
+}
 
-	for cursor.Next(ctx) {
-		var restaurant bson.M // Use `bson.M` instead of `models.Restaurant`
-		if err := cursor.Decode(&restaurant); err != nil {
-			return nil, err
-		}
+// ImportRestaurants stores a batch of restaurants owned by ownerID.
+// Every location is checked before anything is stored.
+func ImportRestaurants(restaurants []models.Restaurant, ownerID string) (*mongo.InsertManyResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-		// Convert `_id` to string if it exists
-		if id, ok := restaurant["_id"].(primitive.ObjectID); ok {
-			restaurant["_id"] = id.Hex() // Convert ObjectID to string
+	prepared := make([]models.Restaurant, len(restaurants))
+	documents := make([]interface{}, len(restaurants))
+	for i, restaurant := range restaurants {
+		if restaurant.Location != nil && !restaurant.Location.Valid() {
+			return nil, ErrInvalidLocation
 		}
-
-		response = append(response, restaurant)
+		prepared[i] = newRestaurant(restaurant, ownerID)
+		documents[i] = prepared[i]
 	}
 
-	if err := cursor.Err(); err != nil {
+	result, err := getRestaurantCollection().InsertMany(ctx, documents)
+	if err != nil {
 		return nil, err
 	}
-	return response, nil
+
+	for _, restaurant := range prepared {
+		if restaurant.GeocodeStatus == models.GeocodePending {
+			enqueueGeocode(restaurant.RestaurantID, restaurant.Address, 1)
+		}
+	}
+	return result, nil
 }
 
 func GetRestaurantByID(id string) (map[string]interface{}, error) {
@@ -88,24 +120,19 @@ func GetRestaurantByID(id string) (map[string]interface{}, error) {
 
 	// Query using restaurant_id instead of _id
 	filter := bson.M{"restaurant_id": id}
-	result := getRestaurantCollection().FindOne(ctx, filter)
-
-	fmt.Println("Searching for RestaurantID:", id)
-
-	// Log query result
+	// Search data is internal
+	result := getRestaurantCollection().FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"search_grams": 0}))
 	if result.Err() != nil {
-		fmt.Println("Error finding document:", result.Err()) // Debugging line
 		return bson.M{}, result.Err()
 	}
 
 	// Decode the result into restaurant variable
 	err := result.Decode(&restaurant)
 	if err != nil {
-		fmt.Println("Error decoding document:", err) // Debugging line
+		log.Println("Error decoding restaurant:", err)
 		return bson.M{}, err
 	}
 
-	fmt.Println("Found restaurant:", restaurant)
 	return restaurant, nil
 }
 
@@ -130,23 +157,68 @@ func GetRestaurantByID(id string) (map[string]interface{}, error) {
 // 	return restaurant, nil
 // }
 
-func UpdateRestaurant(id string, updatedData models.Restaurant) (*mongo.UpdateResult, error) {
+// UpdateRestaurant updates the editable fields of a restaurant the actor may manage
+func UpdateRestaurant(id string, updatedData models.Restaurant, actor dto.Actor) (*mongo.UpdateResult, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	objID, _ := primitive.ObjectIDFromHex(id)
+	current, err := loadManageableRestaurant(ctx, id, actor)
+	if err != nil {
+		return nil, err
+	}
+	if updatedData.Location != nil && !updatedData.Location.Valid() {
+		return nil, ErrInvalidLocation
+	}
+
+	// Ownership fields are changed through their own operations only
+	updatedData.Cuisine = normalizeCuisine(updatedData.Cuisine)
 	update := bson.M{
-		"$set": updatedData,
+		"$set": bson.M{
+			"name":    updatedData.Name,
+			"address": updatedData.Address,
+			"email":   updatedData.Email,
+			"phone":   updatedData.Phone,
+			"cuisine": updatedData.Cuisine,
+
+			"search_grams": helpers.SearchTrigrams(updatedData.Name, updatedData.Cuisine, updatedData.Address),
+		},
 	}
-	result, err := getRestaurantCollection().UpdateOne(ctx, bson.M{"_id": objID}, update)
-	return result, err
+	// The location is kept unless a new one is sent or the address changes
+	geocode := false
+	if updatedData.Location != nil {
+		update["$set"].(bson.M)["location"] = updatedData.Location
+		update["$set"].(bson.M)["geocode_status"] = models.GeocodeManual
+		update["$unset"] = bson.M{"geocode_error": ""}
+	} else if updatedData.Address != current.Address {
+		// The old coordinates belong to the old address, even when there is no geocoder to replace them
+		update["$unset"] = bson.M{"location": "", "geocode_error": "", "geocoded_at": ""}
+		if updatedData.Address != "" && geocodingEnabled() {
+			update["$set"].(bson.M)["geocode_status"] = models.GeocodePending
+			geocode = true
+		} else {
+			update["$unset"].(bson.M)["geocode_status"] = ""
+		}
+	}
+	result, err := getRestaurantCollection().UpdateOne(ctx, bson.M{"restaurant_id": id}, update)
+	if err != nil {
+		return nil, err
+	}
+
+	if geocode {
+		enqueueGeocode(id, updatedData.Address, 1)
+	}
+	return result, nil
 }
 
-func DeleteRestaurant(id string) (*mongo.DeleteResult, error) {
+// DeleteRestaurant removes a restaurant the actor may manage
+func DeleteRestaurant(id string, actor dto.Actor) (*mongo.DeleteResult, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	objID, _ := primitive.ObjectIDFromHex(id)
-	result, err := getRestaurantCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	if _, err := loadManageableRestaurant(ctx, id, actor); err != nil {
+		return nil, err
+	}
+
+	result, err := getRestaurantCollection().DeleteOne(ctx, bson.M{"restaurant_id": id})
 	return result, err
 }
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+)
+
+func TestRestaurantCuisineAndPhoneAreStored(t *testing.T) {
+	db.Reset(t)
+	owner := dto.Actor{UserID: "owner-1"}
+
+	created := newRestaurant(models.Restaurant{
+		Name:    "Place",
+		Address: "1 Market St, Springfield",
+		Email:   "place@example.com",
+		Cuisine: " Italian ",
+	}, owner.UserID)
+	if _, err := ImportRestaurants([]models.Restaurant{{
+		Name:    "Place",
+		Address: "1 Market St, Springfield",
+		Email:   "place@example.com",
+		Cuisine: "Middle Eastern",
+	}}, owner.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if created.Cuisine != "italian" {
+		t.Errorf("created cuisine = %q, want %q", created.Cuisine, "italian")
+	}
+	page, err := GetAllRestaurants(dto.RestaurantListQuery{Cuisine: "MIDDLE EASTERN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 1 || page.Data[0].Cuisine != "middle eastern" {
+		t.Fatalf("imported restaurants listed by cuisine: %+v", page.Data)
+	}
+	id := page.Data[0].RestaurantID
+
+	_, err = UpdateRestaurant(id, models.Restaurant{
+		Name:    "Place",
+		Address: "1 Market St, Springfield",
+		Email:   "place@example.com",
+		Phone:   "+14155552671",
+		Cuisine: "Thai",
+	}, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restaurant := findRestaurant(t, id)
+	if restaurant.Phone != "+14155552671" {
+		t.Errorf("phone = %q, want it updated", restaurant.Phone)
+	}
+	if restaurant.Cuisine != "thai" {
+		t.Errorf("cuisine = %q, want %q", restaurant.Cuisine, "thai")
+	}
+}
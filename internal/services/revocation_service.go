@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var revokedTokenCollection *mongo.Collection
+
+func getRevokedTokenCollection() *mongo.Collection {
+	if revokedTokenCollection == nil {
+		revokedTokenCollection = config.GetCollection(config.DB, "revoked_tokens")
+	}
+	return revokedTokenCollection
+}
+
+// How long a "not revoked" answer is trusted before asking MongoDB again.
+// Revocations made by this process are visible immediately.
+const revocationNegativeCacheTTL = 5 * time.Second
+
+type revocationEntry struct {
+	revoked bool
+	until   time.Time
+}
+
+// revocationCache is the in-process cache in front of the revoked_tokens collection
+var revocationCache = struct {
+	sync.RWMutex
+	entries map[string]revocationEntry
+}{entries: make(map[string]revocationEntry)}
+
+// How often expired entries are dropped from the cache.
+const revocationCacheSweepInterval = time.Minute
+
+var revocationSweeper sync.Once
+
+func cacheRevocation(jti string, revoked bool, until time.Time) {
+	revocationSweeper.Do(func() { go sweepRevocationCache() })
+
+	revocationCache.Lock()
+	defer revocationCache.Unlock()
+	revocationCache.entries[jti] = revocationEntry{revoked: revoked, until: until}
+}
+
+// sweepRevocationCache periodically drops expired entries, keeping lookups off the O(n) path.
+func sweepRevocationCache() {
+	ticker := time.NewTicker(revocationCacheSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		revocationCache.Lock()
+		for key, entry := range revocationCache.entries {
+			if now.After(entry.until) {
+				delete(revocationCache.entries, key)
+			}
+		}
+		revocationCache.Unlock()
+	}
+}
+
+// revokeAccessToken adds an access token to the denylist until it expires.
+func revokeAccessToken(ctx context.Context, jti string, userID string, expiresAt time.Time, reason string) error {
+	if jti == "" || time.Now().After(expiresAt) {
+		return nil
+	}
+
+	cacheRevocation(jti, true, expiresAt)
+
+	_, err := getRevokedTokenCollection().UpdateOne(
+		ctx,
+		bson.M{"jti": jti},
+		bson.M{"$setOnInsert": models.RevokedToken{
+			ID:        primitive.NewObjectID(),
+			JTI:       jti,
+			UserID:    userID,
+			Reason:    reason,
+			ExpiresAt: expiresAt,
+			CreatedAt: time.Now(),
+		}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+// IsAccessTokenRevoked reports whether the access token with the given JTI has been revoked.
+func IsAccessTokenRevoked(jti string) (bool, error) {
+	revocationCache.RLock()
+	entry, ok := revocationCache.entries[jti]
+	revocationCache.RUnlock()
+	if ok && time.Now().Before(entry.until) {
+		return entry.revoked, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var record models.RevokedToken
+	err := getRevokedTokenCollection().FindOne(ctx, bson.M{"jti": jti}).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		cacheRevocation(jti, false, time.Now().Add(revocationNegativeCacheTTL))
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	cacheRevocation(jti, true, record.ExpiresAt)
+	return true, nil
+}
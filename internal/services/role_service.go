@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var roleCollection *mongo.Collection
+
+func getRoleCollection() *mongo.Collection {
+	if roleCollection == nil {
+		roleCollection = config.GetCollection(config.DB, "roles")
+	}
+	return roleCollection
+}
+
+// builtInRoles are created on startup and cannot be deleted
+var builtInRoles = []models.Role{
+	{
+		Name:        models.RoleAdmin,
+		Description: "Full access",
+		Permissions: []string{models.PermissionAll},
+	},
+	{
+		Name:        models.RoleUser,
+		Description: "Default role of registered users",
+		Permissions: []string{models.PermissionRestaurantsRead, models.PermissionRestaurantsWrite},
+	},
+}
+
+// SeedRoles creates the built-in roles if they do not exist yet.
+// Permissions of existing roles are left untouched so admins can adjust them.
+func SeedRoles() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, role := range builtInRoles {
+		now := time.Now()
+		role.ID = primitive.NewObjectID()
+		role.BuiltIn = true
+		role.CreatedAt = now
+		role.UpdatedAt = now
+
+		_, err := getRoleCollection().UpdateOne(
+			ctx,
+			bson.M{"name": role.Name},
+			bson.M{"$setOnInsert": role},
+			options.UpdateOne().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePermissions(permissions []string) error {
+	for _, p := range permissions {
+		if !slices.Contains(models.KnownPermissions, p) {
+			return errors.New("unknown permission: " + p)
+		}
+	}
+	return nil
+}
+
+// GetRoles lists every role
+func GetRoles() ([]models.Role, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := getRoleCollection().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	roles := []models.Role{}
+	if err = cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// CreateRole defines a new role. The actor must hold every permission it grants.
+func CreateRole(input dto.RoleInput, actorID string) (*models.Role, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := validatePermissions(input.Permissions); err != nil {
+		return nil, err
+	}
+	if err := requireActorPermissions(actorID, input.Permissions); err != nil {
+		return nil, err
+	}
+
+	count, err := getRoleCollection().CountDocuments(ctx, bson.M{"name": input.Name})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("role already exists")
+	}
+
+	now := time.Now()
+	role := models.Role{
+		ID:          primitive.NewObjectID(),
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: input.Permissions,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := getRoleCollection().InsertOne(ctx, role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// UpdateRole replaces the description and permissions of a role.
+// Changes apply to the next request of every user with the role.
+// The actor must hold every permission the role grants before and after, and may not change their own role.
+func UpdateRole(name string, input dto.UpdateRoleInput, actorID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := validatePermissions(input.Permissions); err != nil {
+		return err
+	}
+	if name == models.RoleAdmin && !slices.Contains(input.Permissions, models.PermissionAll) {
+		return errors.New("the admin role must keep all permissions")
+	}
+
+	var role models.Role
+	if err := getRoleCollection().FindOne(ctx, bson.M{"name": name}).Decode(&role); err != nil {
+		return errors.New("role not found")
+	}
+	var actor models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": actorID}).Decode(&actor); err != nil {
+		return errors.New("user not found")
+	}
+	if actor.Role == name {
+		return ErrOwnRole
+	}
+	if err := requireActorPermissions(actorID, append(role.Permissions, input.Permissions...)); err != nil {
+		return err
+	}
+
+	result, err := getRoleCollection().UpdateOne(
+		ctx,
+		bson.M{"name": name},
+		bson.M{"$set": bson.M{"description": input.Description, "permissions": input.Permissions, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("role not found")
+	}
+	return nil
+}
+
+// DeleteRole removes a role that is neither built in nor assigned to any user
+func DeleteRole(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var role models.Role
+	if err := getRoleCollection().FindOne(ctx, bson.M{"name": name}).Decode(&role); err != nil {
+		return errors.New("role not found")
+	}
+	if role.BuiltIn {
+		return errors.New("built-in roles cannot be deleted")
+	}
+
+	count, err := getUserCollection().CountDocuments(ctx, bson.M{"role": name})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("role is still assigned to users")
+	}
+
+	_, err = getRoleCollection().DeleteOne(ctx, bson.M{"name": name})
+	return err
+}
+
+// roleExists reports whether a role with the name is defined.
+func roleExists(ctx context.Context, name string) (bool, error) {
+	count, err := getRoleCollection().CountDocuments(ctx, bson.M{"name": name})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ErrInsufficientPermissions is returned when an admin would grant, or act on a user who holds,
+// permissions the admin does not have.
+var ErrInsufficientPermissions = errors.New("you cannot grant or manage permissions you do not have")
+
+// ErrOwnRole is returned when an admin tries to change the role they hold.
+var ErrOwnRole = errors.New("you cannot change a role you hold")
+
+// GetUserPermissions resolves the permissions of a user from their current role.
+// It is called on every request so role changes apply without waiting for token expiry.
+func GetUserPermissions(userID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil {
+		return nil, errors.New("user not found")
+	}
+	return rolePermissions(ctx, user.Role)
+}
+
+// rolePermissions returns the permissions granted by a role, none for an unknown role.
+func rolePermissions(ctx context.Context, name string) ([]string, error) {
+	var role models.Role
+	err := getRoleCollection().FindOne(ctx, bson.M{"name": name}).Decode(&role)
+	if err == mongo.ErrNoDocuments {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return role.Permissions, nil
+}
+
+// requireActorPermissions returns ErrInsufficientPermissions unless the actor holds every one of the permissions.
+func requireActorPermissions(actorID string, permissions []string) error {
+	granted, err := GetUserPermissions(actorID)
+	if err != nil {
+		return err
+	}
+	if !models.HasPermissions(granted, permissions) {
+		return ErrInsufficientPermissions
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var sessionCollection *mongo.Collection
+
+func getSessionCollection() *mongo.Collection {
+	if sessionCollection == nil {
+		sessionCollection = config.GetCollection(config.DB, "sessions")
+	}
+	return sessionCollection
+}
+
+// startSession creates a session for the user and issues its first token pair.
+func startSession(ctx context.Context, user *models.User, client dto.ClientInfo) (*dto.TokenPair, error) {
+	sessionID, err := helpers.GenerateRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	device := client.Device
+	if device == "" {
+		device = helpers.DescribeDevice(client.UserAgent)
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:         primitive.NewObjectID(),
+		SessionID:  sessionID,
+		UserID:     user.UserID,
+		Device:     device,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(helpers.RefreshTokenTTL),
+	}
+	if _, err := getSessionCollection().InsertOne(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return issueTokenPair(ctx, user, sessionID)
+}
+
+// touchSession records activity on a session after its refresh token was rotated.
+func touchSession(ctx context.Context, sessionID string, client dto.ClientInfo, expiresAt time.Time) error {
+	result, err := getSessionCollection().UpdateOne(
+		ctx,
+		bson.M{"session_id": sessionID, "revoked": false},
+		bson.M{"$set": bson.M{
+			"last_used_at": time.Now(),
+			"user_agent":   client.UserAgent,
+			"ip":           client.IP,
+			"expires_at":   expiresAt,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("session revoked")
+	}
+	return nil
+}
+
+// revokeSession revokes the session, its current access token and every refresh token of its family.
+func revokeSession(ctx context.Context, sessionID string, reason string) error {
+	var session models.Session
+	err := getSessionCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"session_id": sessionID, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true, "revoked_at": time.Now()}},
+	).Decode(&session)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if err == nil {
+		if err := revokeAccessToken(ctx, session.AccessJTI, session.UserID, session.AccessExpiresAt, reason); err != nil {
+			return err
+		}
+	}
+	return revokeTokenFamily(ctx, sessionID)
+}
+
+// revokeUserSessions revokes every session of the user, including their access tokens.
+func revokeUserSessions(ctx context.Context, userID string, reason string) error {
+	cursor, err := getSessionCollection().Find(ctx, bson.M{"user_id": userID, "revoked": false})
+	if err != nil {
+		return err
+	}
+	var sessions []models.Session
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if err := revokeSession(ctx, session.SessionID, reason); err != nil {
+			return err
+		}
+	}
+	return revokeUserRefreshTokens(ctx, userID)
+}
+
+// revokeOtherSessions revokes every session of the user except keepSessionID.
+func revokeOtherSessions(ctx context.Context, userID string, keepSessionID string, reason string) error {
+	cursor, err := getSessionCollection().Find(ctx, bson.M{
+		"user_id":    userID,
+		"revoked":    false,
+		"session_id": bson.M{"$ne": keepSessionID},
+	})
+	if err != nil {
+		return err
+	}
+	var sessions []models.Session
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if err := revokeSession(ctx, session.SessionID, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserSessions lists the active sessions of a user, most recently used first.
+// The session matching currentSessionID is flagged as current.
+func GetUserSessions(userID string, currentSessionID string) ([]dto.SessionView, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := getSessionCollection().Find(
+		ctx,
+		bson.M{"user_id": userID, "revoked": false, "expires_at": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.M{"last_used_at": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []models.Session
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	views := make([]dto.SessionView, 0, len(sessions))
+	for _, s := range sessions {
+		views = append(views, dto.SessionView{
+			SessionID:  s.SessionID,
+			Device:     s.Device,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			Current:    s.SessionID == currentSessionID,
+		})
+	}
+	return views, nil
+}
+
+// RevokeSession revokes one of the user's own sessions
+func RevokeSession(userID string, sessionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count, err := getSessionCollection().CountDocuments(ctx, bson.M{"user_id": userID, "session_id": sessionID, "revoked": false})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("session not found")
+	}
+	return revokeSession(ctx, sessionID, "session_revoked")
+}
+
+// RevokeAllSessions logs the user out everywhere
+func RevokeAllSessions(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return revokeUserSessions(ctx, userID, "logout_all")
+}
+
+// Logout revokes the session identified by the refresh token or, failing that, the access token.
+// Only that session is signed out; other devices of the user stay signed in.
+func Logout(refreshToken string, accessToken string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var userID, sessionID string
+	if claims, err := helpers.ValidateRefreshToken(refreshToken); err == nil {
+		userID, _ = claims["user_id"].(string)
+		sessionID, _ = claims["family_id"].(string)
+	} else if claims, err := helpers.ValidateAccessToken(accessToken); err == nil {
+		userID, _ = claims["user_id"].(string)
+		sessionID, _ = claims["sid"].(string)
+	}
+	if userID == "" || sessionID == "" {
+		return errors.New("authentication required")
+	}
+
+	// The presented access token may predate the session's current one
+	if claims, err := helpers.ValidateAccessToken(accessToken); err == nil && claims["user_id"] == userID {
+		jti, _ := claims["jti"].(string)
+		if err := revokeAccessToken(ctx, jti, userID, claimExpiry(claims), "logout"); err != nil {
+			return err
+		}
+	}
+
+	count, err := getSessionCollection().CountDocuments(ctx, bson.M{"user_id": userID, "session_id": sessionID})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("authentication required")
+	}
+	return revokeSession(ctx, sessionID, "logout")
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var refreshTokenCollection *mongo.Collection
+
+func getRefreshTokenCollection() *mongo.Collection {
+	if refreshTokenCollection == nil {
+		refreshTokenCollection = config.GetCollection(config.DB, "refresh_tokens")
+	}
+	return refreshTokenCollection
+}
+
+// issueTokenPair generates a token pair for the user and stores the refresh token record.
+// An empty familyID starts a new token family.
+func issueTokenPair(ctx context.Context, user *models.User, familyID string) (*dto.TokenPair, error) {
+	tokens, err := helpers.GenerateTokenPair(user.UserID, user.Role, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	record := models.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		JTI:       tokens.RefreshJTI,
+		FamilyID:  tokens.FamilyID,
+		UserID:    user.UserID,
+		ExpiresAt: tokens.RefreshExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	if _, err := getRefreshTokenCollection().InsertOne(ctx, record); err != nil {
+		return nil, err
+	}
+
+	// Remember the new access token on the session and retire the previous one. revokeSession
+	// only knows the session's latest access token, so without this a logout would leave
+	// tokens issued before the last refresh usable until they expire.
+	var previous models.Session
+	err = getSessionCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"session_id": tokens.FamilyID},
+		bson.M{"$set": bson.M{"access_jti": tokens.AccessJTI, "access_expires_at": tokens.AccessExpiresAt}},
+	).Decode(&previous)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if err == nil {
+		if err := revokeAccessToken(ctx, previous.AccessJTI, user.UserID, previous.AccessExpiresAt, "rotated"); err != nil {
+			return nil, err
+		}
+	}
+
+	return tokens, nil
+}
+
+// claimExpiry returns the "exp" claim of a token as a time.
+func claimExpiry(claims map[string]interface{}) time.Time {
+	switch exp := claims["exp"].(type) {
+	case float64:
+		return time.Unix(int64(exp), 0)
+	case int64:
+		return time.Unix(exp, 0)
+	}
+	return time.Time{}
+}
+
+// RotateRefreshToken exchanges a valid refresh token for a new token pair in the same family.
+// Presenting a token that was already used revokes the whole family and its session.
+func RotateRefreshToken(refreshToken string, client dto.ClientInfo) (*dto.TokenPair, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	claims, err := helpers.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+	jti, _ := claims["jti"].(string)
+	familyID, _ := claims["family_id"].(string)
+	if jti == "" || familyID == "" {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	// Mark the token as used, only if it is still active
+	now := time.Now()
+	result, err := getRefreshTokenCollection().UpdateOne(
+		ctx,
+		bson.M{"jti": jti, "family_id": familyID, "used": false, "revoked": false},
+		bson.M{"$set": bson.M{"used": true, "used_at": now}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.MatchedCount == 0 {
+		var record models.RefreshToken
+		err := getRefreshTokenCollection().FindOne(ctx, bson.M{"jti": jti}).Decode(&record)
+		if err != nil {
+			return nil, errors.New("token revoked")
+		}
+
+		if record.Used {
+			// A rotated token was presented again: assume it was stolen and kill the family
+			log.Printf("refresh token reuse detected: user=%s family=%s jti=%s", record.UserID, record.FamilyID, record.JTI)
+			if err := revokeSession(ctx, record.FamilyID, "refresh_token_reuse"); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("refresh token reuse detected")
+		}
+		return nil, errors.New("token revoked")
+	}
+
+	var user models.User
+	err = getUserCollection().FindOne(ctx, bson.M{"user_id": claims["user_id"]}).Decode(&user)
+	if err != nil || user.Disabled {
+		return nil, errors.New("token revoked")
+	}
+
+	// Touch the session first, so a revoked session never gets new tokens
+	if err := touchSession(ctx, familyID, client, now.Add(helpers.RefreshTokenTTL)); err != nil {
+		return nil, err
+	}
+
+	tokens, err := issueTokenPair(ctx, &user, familyID)
+	if err != nil {
+		// Let the client retry with the same token instead of tripping reuse detection
+		if _, releaseErr := getRefreshTokenCollection().UpdateOne(
+			ctx,
+			bson.M{"jti": jti},
+			bson.M{"$set": bson.M{"used": false}, "$unset": bson.M{"used_at": ""}},
+		); releaseErr != nil {
+			log.Println("Failed to release refresh token", jti+":", releaseErr)
+		}
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// revokeTokenFamily revokes every refresh token in the family.
+func revokeTokenFamily(ctx context.Context, familyID string) error {
+	_, err := getRefreshTokenCollection().UpdateMany(
+		ctx,
+		bson.M{"family_id": familyID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
+
+// revokeUserRefreshTokens revokes every refresh token issued to the user.
+func revokeUserRefreshTokens(ctx context.Context, userID string) error {
+	_, err := getRefreshTokenCollection().UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return err
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/dto"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// auditUserAction records an admin action on a user account.
+func auditUserAction(ctx context.Context, action string, actorID string, userID string, details map[string]interface{}) error {
+	return recordAudit(ctx, models.AuditLog{
+		Action:     action,
+		ActorID:    actorID,
+		TargetType: "user",
+		TargetID:   userID,
+		Details:    details,
+	})
+}
+
+// ErrUserOwnsRestaurants is returned when deleting a user who still owns restaurants.
+var ErrUserOwnsRestaurants = errors.New("user still owns restaurants, transfer them first")
+
+// requireActorOutranks returns ErrInsufficientPermissions unless the actor holds every permission
+// of the user, so admins cannot take over accounts more privileged than their own.
+func requireActorOutranks(actorID string, userID string) error {
+	permissions, err := GetUserPermissions(userID)
+	if err != nil {
+		return err
+	}
+	return requireActorPermissions(actorID, permissions)
+}
+
+// CreateUser creates an account on behalf of an admin. The email is trusted as verified.
+// Giving it another role than the default requires roles:manage and every permission of the role.
+func CreateUser(input dto.CreateUserInput, actorID string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	role := input.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	exists, err := roleExists(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("role not found")
+	}
+
+	// Any other role than the default is a role assignment, and only of permissions the admin holds
+	if role != models.RoleUser {
+		permissions, err := rolePermissions(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		if err := requireActorPermissions(actorID, append(permissions, models.PermissionRolesManage)); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateNewPassword(input.Password, input.Email, input.Name); err != nil {
+		return nil, err
+	}
+
+	user, err := createUser(ctx, input.Name, input.Email, input.Password, role)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = getUserCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": user.UserID},
+		bson.M{"$set": bson.M{"email_verified": true, "email_verified_at": now}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+
+	return user, auditUserAction(ctx, "user.created", actorID, user.UserID, map[string]interface{}{"role": role})
+}
+
+// UpdateUser changes the name and/or email of a user
+func UpdateUser(userID string, input dto.UpdateUserInput, actorID string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := requireActorOutranks(actorID, userID); err != nil {
+		return nil, err
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	if input.Name != "" {
+		set["name"] = input.Name
+	}
+	if input.Email != "" {
+		var existing models.User
+		err := getUserCollection().FindOne(ctx, bson.M{"email": input.Email}).Decode(&existing)
+		if err == nil && existing.UserID != userID {
+			return nil, errors.New("email already exists")
+		}
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+		set["email"] = input.Email
+	}
+
+	var user models.User
+	err := getUserCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, auditUserAction(ctx, "user.updated", actorID, userID, map[string]interface{}{"name": input.Name, "email": input.Email})
+}
+
+// SetUserDisabled disables or re-enables an account. Disabling signs the user out everywhere.
+func SetUserDisabled(userID string, disabled bool, actorID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := requireActorOutranks(actorID, userID); err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{"disabled": false, "updated_at": time.Now()}, "$unset": bson.M{"disabled_at": ""}}
+	action := "user.enabled"
+	if disabled {
+		update = bson.M{"$set": bson.M{"disabled": true, "disabled_at": time.Now(), "updated_at": time.Now()}}
+		action = "user.disabled"
+	}
+
+	result, err := getUserCollection().UpdateOne(ctx, bson.M{"user_id": userID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+
+	if disabled {
+		if err := revokeUserSessions(ctx, userID, "user_disabled"); err != nil {
+			return err
+		}
+	}
+	return auditUserAction(ctx, action, actorID, userID, nil)
+}
+
+// DeleteUser removes an account with its credentials and grants: sessions are revoked, and API keys,
+// OAuth apps, consents and linked external identities are deleted. Users who still own restaurants
+// are refused, and are removed as managers otherwise.
+func DeleteUser(userID string, actorID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := requireActorOutranks(actorID, userID); err != nil {
+		return err
+	}
+
+	owned, err := getRestaurantCollection().CountDocuments(ctx, bson.M{"owner_id": userID})
+	if err != nil {
+		return err
+	}
+	if owned > 0 {
+		return ErrUserOwnsRestaurants
+	}
+
+	if err := revokeUserSessions(ctx, userID, "user_deleted"); err != nil {
+		return err
+	}
+	if err := deleteUserGrants(ctx, userID); err != nil {
+		return err
+	}
+
+	result, err := getUserCollection().DeleteOne(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("user not found")
+	}
+
+	return auditUserAction(ctx, "user.deleted", actorID, userID, nil)
+}
+
+// deleteUserGrants removes everything that lets the user, or apps acting for them, back in.
+func deleteUserGrants(ctx context.Context, userID string) error {
+	if _, err := getAPIKeyCollection().DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+
+	// Apps the user registered, with the consents and tokens other users gave them
+	var clients []models.OAuthClient
+	cursor, err := getOAuthClientCollection().Find(ctx, bson.M{"owner_id": userID})
+	if err != nil {
+		return err
+	}
+	if err := cursor.All(ctx, &clients); err != nil {
+		return err
+	}
+	clientIDs := make([]string, len(clients))
+	for i, client := range clients {
+		clientIDs[i] = client.ClientID
+	}
+	if len(clientIDs) > 0 {
+		if err := revokeOAuthTokens(ctx, bson.M{"client_id": bson.M{"$in": clientIDs}}, "client_deleted"); err != nil {
+			return err
+		}
+		if _, err := getOAuthConsentCollection().DeleteMany(ctx, bson.M{"client_id": bson.M{"$in": clientIDs}}); err != nil {
+			return err
+		}
+		if _, err := getOAuthCodeCollection().DeleteMany(ctx, bson.M{"client_id": bson.M{"$in": clientIDs}}); err != nil {
+			return err
+		}
+		if _, err := getOAuthClientCollection().DeleteMany(ctx, bson.M{"owner_id": userID}); err != nil {
+			return err
+		}
+	}
+
+	// Access the user granted to apps
+	if err := revokeOAuthTokens(ctx, bson.M{"user_id": userID}, "user_deleted"); err != nil {
+		return err
+	}
+	if _, err := getOAuthConsentCollection().DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := getOAuthCodeCollection().DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+
+	if _, err := getExternalIdentityCollection().DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := getUserTokenCollection().DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	_, err = getRestaurantCollection().UpdateMany(
+		ctx,
+		bson.M{"manager_ids": userID},
+		bson.M{"$pull": bson.M{"manager_ids": userID}},
+	)
+	return err
+}
+
+// AdminSetPassword replaces a user's password and signs them out everywhere
+func AdminSetPassword(userID string, password string, actorID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := requireActorOutranks(actorID, userID); err != nil {
+		return err
+	}
+
+	if err := setUserPassword(ctx, userID, password); err != nil {
+		return err
+	}
+
+	if err := revokeUserSessions(ctx, userID, "password_reset_by_admin"); err != nil {
+		return err
+	}
+	return auditUserAction(ctx, "user.password_reset", actorID, userID, nil)
+}
+
+// IsUserDisabled reports whether the user is disabled or no longer exists
+func IsUserDisabled(userID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := getUserCollection().FindOne(
+		ctx,
+		bson.M{"user_id": userID},
+		options.FindOne().SetProjection(bson.M{"disabled": 1}),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.Disabled, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alpha-154/crud-go-gin/internal/config"
+	"github.com/alpha-154/crud-go-gin/internal/helpers"
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var userTokenCollection *mongo.Collection
+
+func getUserTokenCollection() *mongo.Collection {
+	if userTokenCollection == nil {
+		userTokenCollection = config.GetCollection(config.DB, "user_tokens")
+	}
+	return userTokenCollection
+}
+
+// createUserToken issues a single-use token for the user and returns it in clear text.
+// Unused tokens issued earlier for the same purpose stop working.
+func createUserToken(ctx context.Context, userID string, purpose string, ttl time.Duration) (string, error) {
+	token, err := helpers.GenerateRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = getUserTokenCollection().UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "purpose": purpose, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": now}},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	record := models.UserToken{
+		ID:        primitive.NewObjectID(),
+		TokenHash: helpers.HashToken(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if _, err := getUserTokenCollection().InsertOne(ctx, record); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeUserToken marks a valid token as used and returns its record.
+func consumeUserToken(ctx context.Context, token string, purpose string) (*models.UserToken, error) {
+	var record models.UserToken
+	err := getUserTokenCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"token_hash": helpers.HashToken(token),
+			"purpose":    purpose,
+			"used_at":    bson.M{"$exists": false},
+			"expires_at": bson.M{"$gt": time.Now()},
+		},
+		bson.M{"$set": bson.M{"used_at": time.Now()}},
+	).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("invalid or expired token")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,157 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/alpha-154/crud-go-gin/internal/models"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is one field of a request body that failed validation.
+// Code is the name of the broken rule, e.g. "required" or "email".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Code    string `json:"code"`
+}
+
+// Cuisines accepted when RESTAURANT_CUISINES is not set.
+var defaultCuisines = []string{
+	"american", "bakery", "barbecue", "cafe", "chinese", "french", "greek", "indian",
+	"italian", "japanese", "korean", "mediterranean", "mexican", "middle eastern",
+	"pizza", "seafood", "spanish", "steakhouse", "thai", "turkish", "vegetarian", "vietnamese",
+}
+
+// An address is a street and a city at least, like "12 Main St, Springfield".
+var addressPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} .,'#/&()-]{4,199}$`)
+
+var (
+	cuisines     map[string]bool
+	cuisinesOnce sync.Once
+)
+
+// allowedCuisines reads RESTAURANT_CUISINES on first use, once the environment is loaded.
+func allowedCuisines() map[string]bool {
+	cuisinesOnce.Do(func() {
+		allowed := defaultCuisines
+		if list := os.Getenv("RESTAURANT_CUISINES"); list != "" {
+			allowed = strings.Split(list, ",")
+		}
+		cuisines = make(map[string]bool, len(allowed))
+		for _, c := range allowed {
+			if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+				cuisines[c] = true
+			}
+		}
+	})
+	return cuisines
+}
+
+// The custom rules below are added to the validator behind gin's binding tags as soon as
+// the package is linked in, and errors name fields after their JSON keys:
+//
+//	cuisine   one of the comma separated RESTAURANT_CUISINES (case insensitive, defaults to a built-in list)
+//	address   letters, digits and common punctuation, 5 to 200 characters
+//	geopoint  a GeoJSON point with coordinates on the globe
+func init() {
+	if err := register(); err != nil {
+		panic("validation: " + err.Error())
+	}
+}
+
+func register() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	rules := map[string]validator.Func{
+		"cuisine": func(fl validator.FieldLevel) bool {
+			return allowedCuisines()[strings.ToLower(strings.TrimSpace(fl.Field().String()))]
+		},
+		"address": func(fl validator.FieldLevel) bool {
+			return addressPattern.MatchString(strings.TrimSpace(fl.Field().String()))
+		},
+		"geopoint": func(fl validator.FieldLevel) bool {
+			point, ok := fl.Field().Interface().(models.GeoPoint)
+			return ok && point.Valid()
+		},
+	}
+	for tag, rule := range rules {
+		if err := v.RegisterValidation(tag, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FieldErrors turns the error of a failed binding into field errors.
+// It reports false for errors that are not about field values, like malformed JSON.
+func FieldErrors(err error) ([]FieldError, bool) {
+	var failed validator.ValidationErrors
+	if !errors.As(err, &failed) {
+		return nil, false
+	}
+
+	errs := make([]FieldError, len(failed))
+	for i, fe := range failed {
+		errs[i] = FieldError{Field: fieldPath(fe), Message: message(fe), Code: fe.Tag()}
+	}
+	return errs, true
+}
+
+// fieldPath drops the struct name from the namespace, e.g. "Restaurant.email" becomes "email".
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "e164":
+		return "must be a phone number in E.164 format, e.g. +14155552671"
+	case "cuisine":
+		return "must be one of the supported cuisines"
+	case "address":
+		return "must be a street address of 5 to 200 letters, digits and common punctuation"
+	case "geopoint":
+		return "must be a GeoJSON point with [longitude, latitude] coordinates"
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters", fe.Param())
+		}
+		return fmt.Sprintf("must have at least %s entries", fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters", fe.Param())
+		}
+		return fmt.Sprintf("must have at most %s entries", fe.Param())
+	}
+	return "is invalid"
+}